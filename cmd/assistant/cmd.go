@@ -6,16 +6,50 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	assistant "github.com/dvonthenen/open-virtual-assistant/pkg/assistant"
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
 	initlib "github.com/dvonthenen/open-virtual-assistant/pkg/init"
+	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
+	postprocess "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/postprocess"
 
 	assistantimpl "github.com/dvonthenen/open-virtual-assistant/cmd/assistant/impl"
 )
 
+// assistantOptions used by the live assistant and for transcribing files
+func assistantOptions() *assistant.AssistantOptions {
+	return &assistant.AssistantOptions{
+		PostProcess: postprocess.New(
+			postprocess.Lowercase(),
+			postprocess.RemoveFillers(),
+		),
+	}
+}
+
+func transcribeFile(filePath string) {
+	transcript, err := assistant.TranscribeFile(context.Background(), filePath, assistantOptions())
+	if err != nil {
+		fmt.Printf("assistant.TranscribeFile failed. Err: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%s (%s, %s, %v)\n\n", filePath, transcript.Backend, transcript.Language, transcript.Duration.Round(time.Millisecond))
+	for _, segment := range transcript.Segments {
+		fmt.Printf("[%s - %s] %s\n", formatOffset(segment.Start), formatOffset(segment.End), segment.Text)
+	}
+	fmt.Printf("\n")
+}
+
+func formatOffset(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	return fmt.Sprintf("%02d:%02d.%03d", int(d.Minutes()), int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
 func main() {
 	/*
 		Init
@@ -24,6 +58,26 @@ func main() {
 		LogLevel: initlib.LogLevelStandard, // LogLevelStandard / LogLevelFull / LogLevelTrace / LogLevelVerbose
 	})
 
+	/*
+		Subcommands
+	*/
+	switch flag.Arg(0) {
+	case "devices":
+		err := microphone.PrintDevices(os.Stdout)
+		if err != nil {
+			fmt.Printf("microphone.PrintDevices failed. Err: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "transcribe":
+		if flag.NArg() < 2 {
+			fmt.Printf("Usage: assistant transcribe <file.wav|file.mp3|gs://bucket/file>\n")
+			os.Exit(1)
+		}
+		transcribeFile(flag.Arg(1))
+		return
+	}
+
 	/*
 		Assistant
 	*/
@@ -32,7 +86,7 @@ func main() {
 	var assistImpl interfaces.AssistantImpl
 	assistImpl = myAssistant
 
-	assist, err := assistant.New(&assistImpl, &assistant.AssistantOptions{})
+	assist, err := assistant.New(&assistImpl, assistantOptions())
 	if err != nil {
 		fmt.Printf("assistant.New failed. Err: %v\n", err)
 		os.Exit(1)
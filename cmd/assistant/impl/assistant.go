@@ -17,7 +17,9 @@ import (
 	personas "github.com/dvonthenen/chat-gpeasy/pkg/personas"
 	gpeasyinterfaces "github.com/dvonthenen/chat-gpeasy/pkg/personas/interfaces"
 
+	ainterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
 )
 
 func New() *MyAssistant {
@@ -32,18 +34,8 @@ func (a *MyAssistant) SetSpeech(s *interfaces.Speech) {
 	a.speech = s
 }
 
-func (a *MyAssistant) Response(text string) error {
-	text = strings.ToLower(text)
-	klog.V(5).Infof("text: %s\n", text)
-
-	// Check if text contains at least one Greet and Name Words founds
-	words := strings.Split(text, " ")
-
-	if len(words) < 3 {
-		klog.V(4).Infof("Not enough words to process. Skipping...\n")
-		return nil
-	}
-
+// wakeWords finds a greeting and one of kitt's names near the start of the sentence
+func wakeWords(words []string) (string, string) {
 	foundGreet := ""
 	for _, greet := range GreetingWords {
 		klog.V(6).Infof("checking grett word = %s\n", greet)
@@ -64,6 +56,47 @@ func (a *MyAssistant) Response(text string) error {
 		}
 	}
 
+	return foundGreet, foundName
+}
+
+// TriggeredTranscript implements ainterfaces.TriggerCallback. Kitt is triggered when
+// greeted by name or when the sentence is the prompt for a new job.
+func (a *MyAssistant) TriggeredTranscript(t *tinterfaces.Transcript) (*ainterfaces.Reply, error) {
+	words := strings.Split(strings.ToLower(t.Text), " ")
+
+	triggered := false
+	if len(words) >= 3 {
+		foundGreet, foundName := wakeWords(words)
+		triggered = (foundGreet != "" && foundName != "") || a.activeJob != nil
+	}
+
+	text, err := a.respond(t.Text)
+	return &ainterfaces.Reply{
+		Triggered: triggered,
+		Text:      text,
+	}, err
+}
+
+func (a *MyAssistant) Response(text string) error {
+	_, err := a.respond(text)
+	return err
+}
+
+// respond handles the sentence and returns whatever kitt said back
+func (a *MyAssistant) respond(text string) (string, error) {
+	text = strings.ToLower(text)
+	klog.V(5).Infof("text: %s\n", text)
+
+	// Check if text contains at least one Greet and Name Words founds
+	words := strings.Split(text, " ")
+
+	if len(words) < 3 {
+		klog.V(4).Infof("Not enough words to process. Skipping...\n")
+		return "", nil
+	}
+
+	foundGreet, foundName := wakeWords(words)
+
 	// If both found, activate kitt
 	if foundGreet != "" && foundName != "" {
 		klog.V(2).Infof("Greeting=%s and Name=%s found. Asking kitt.\n", foundGreet, foundName)
@@ -72,12 +105,12 @@ func (a *MyAssistant) Response(text string) error {
 		regTask, err := regexp.Compile("(activate|create|resume)\\s(a|the)??\\stask\\s(name|named|called)\\s{1}([a-z\\s]+)")
 		if err != nil {
 			klog.V(1).Infof("regexp.Compile failed. Err: %v\n", err)
-			return err
+			return "", err
 		}
 		regJob, err := regexp.Compile("(create)\\s(a|the)??\\sjob\\s(name|named|called)\\s{1}([a-z\\s]+)")
 		if err != nil {
 			klog.V(1).Infof("regexp.Compile failed. Err: %v\n", err)
-			return err
+			return "", err
 		}
 
 		if regJob.MatchString(text) {
@@ -96,13 +129,13 @@ func (a *MyAssistant) Response(text string) error {
 				personaConfig, err := personas.DefaultConfig("", "")
 				if err != nil {
 					klog.V(1).Infof("personas.DefaultConfig error: %v\n", err)
-					return err
+					return "", err
 				}
 
 				persona, err := personas.NewAdvancedChatStreamWithOptions(personaConfig)
 				if err != nil {
 					klog.V(1).Infof("personas.NewAdvancedChatStreamWithOptions failed. Err: %v\n", err)
-					return err
+					return "", err
 				}
 
 				(*persona).Init(gpeasyinterfaces.SkillTypeGeneric, "")
@@ -118,13 +151,14 @@ func (a *MyAssistant) Response(text string) error {
 			// clear active task
 			a.activeTask = nil
 
-			err = (*a.speech).Play(context.Background(), fmt.Sprintf("The job called %s has been %sd. What would you like me to research?", jobName, jobAction))
+			reply := fmt.Sprintf("The job called %s has been %sd. What would you like me to research?", jobName, jobAction)
+			err = (*a.speech).Play(context.Background(), reply)
 			if err != nil {
 				klog.V(1).Infof("personas.DefaultConfig error: %v\n", err)
-				return err
+				return "", err
 			}
 
-			return nil
+			return reply, nil
 		} else if regTask.MatchString(text) {
 			klog.V(2).Infof("Creating/activating a task...\n")
 
@@ -141,13 +175,13 @@ func (a *MyAssistant) Response(text string) error {
 				personaConfig, err := personas.DefaultConfig("", "")
 				if err != nil {
 					klog.V(1).Infof("personas.DefaultConfig error: %v\n", err)
-					return err
+					return "", err
 				}
 
 				persona, err := personas.NewAdvancedChatStreamWithOptions(personaConfig)
 				if err != nil {
 					klog.V(1).Infof("personas.NewAdvancedChatStreamWithOptions failed. Err: %v\n", err)
-					return err
+					return "", err
 				}
 
 				(*persona).Init(gpeasyinterfaces.SkillTypeGeneric, "")
@@ -163,54 +197,55 @@ func (a *MyAssistant) Response(text string) error {
 			// clear active job
 			a.activeJob = nil
 
-			err = (*a.speech).Play(context.Background(), fmt.Sprintf("The task called %s has been %sd.", taskName, taskAction))
+			reply := fmt.Sprintf("The task called %s has been %sd.", taskName, taskAction)
+			err = (*a.speech).Play(context.Background(), reply)
 			if err != nil {
 				klog.V(1).Infof("personas.DefaultConfig error: %v\n", err)
-				return err
+				return "", err
 			}
 
-			return nil
+			return reply, nil
 		}
 
 		// activate task or job?
 		regActionSkip, err := regexp.Compile("(activate|create|resume)+")
 		if err != nil {
 			klog.V(1).Infof("regexp.Compile failed. Err: %v\n", err)
-			return err
+			return "", err
 		}
 		regItemSkip, err := regexp.Compile("(credit|task|job)+")
 		if err != nil {
 			klog.V(1).Infof("regexp.Compile failed. Err: %v\n", err)
-			return err
+			return "", err
 		}
 
 		if regActionSkip.MatchString(text) || regItemSkip.MatchString(text) {
 			klog.V(2).Infof("Skip using KITT...\n")
-			return nil
+			return "", nil
 		}
 
 		// active task?
 		if a.activeTask != nil {
 			klog.V(2).Infof("Active task found. Asking kitt.\n")
 
-			err := a.activetaskQuestion(text)
+			reply, err := a.activetaskQuestion(text)
 			if err != nil {
 				klog.V(1).Infof("activetaskQuestion failed. Err: %v\n", err)
 			} else {
 				klog.V(4).Infof("activetaskQuestion succeeded. text: %s\n", text)
 			}
 
-			return nil
+			return reply, nil
 		}
 
 		// throwaway but need to answer
 		klog.V(2).Infof("No active task found. Creating a throwaway.\n")
 
-		err = a.throwawayQuestion(text)
+		reply, err := a.throwawayQuestion(text)
 		if err != nil {
 			klog.V(1).Infof("throwawayQuestion failed. Err: %v\n", err)
 		}
-		return err
+		return reply, err
 	} else if a.activeJob != nil {
 		klog.V(2).Infof("This is not a message for Kitt. This is the start to launching a job.\n")
 
@@ -225,15 +260,18 @@ func (a *MyAssistant) Response(text string) error {
 		// 	return err
 		// }
 
-		err := (*a.speech).Play(context.Background(), fmt.Sprintf("Launching long running job will report back when finished. Prompt: %s. Starting job now!", text))
+		reply := fmt.Sprintf("Launching long running job will report back when finished. Prompt: %s. Starting job now!", text)
+		err := (*a.speech).Play(context.Background(), reply)
 		if err != nil {
 			klog.V(1).Infof("personas.DefaultConfig error: %v\n", err)
-			return err
+			return "", err
 		}
 
 		// TODO: commenting this out for demo purposes
 		a.activeJob = nil
 
+		return reply, nil
+
 	} else if a.activeTask != nil {
 		klog.V(2).Infof("This is not a message for Kitt. Adding to activate task.\n")
 
@@ -242,24 +280,24 @@ func (a *MyAssistant) Response(text string) error {
 			klog.V(1).Infof("activeTask.AddUserContext failed. Err: %v\n", err)
 		}
 
-		return err
+		return "", err
 	}
 
-	return nil
+	return "", nil
 }
 
-func (a *MyAssistant) throwawayQuestion(text string) error {
+func (a *MyAssistant) throwawayQuestion(text string) (string, error) {
 	// create chatgpt client
 	personaConfig, err := personas.DefaultConfig("", "")
 	if err != nil {
 		klog.V(1).Infof("personas.DefaultConfig failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	persona, err := personas.NewAdvancedChatStreamWithOptions(personaConfig)
 	if err != nil {
 		klog.V(1).Infof("personas.NewAdvancedChatStreamWithOptions failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	(*persona).Init(gpeasyinterfaces.SkillTypeGeneric, "")
@@ -267,7 +305,7 @@ func (a *MyAssistant) throwawayQuestion(text string) error {
 	stream, err := (*persona).Query(context.Background(), text)
 	if err != nil {
 		klog.V(1).Infof("personas.Query failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	// convert stream to string
@@ -276,7 +314,7 @@ func (a *MyAssistant) throwawayQuestion(text string) error {
 	err = (*stream).Stream(sb)
 	if err != nil {
 		klog.V(1).Infof("stream.Stream failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 	(*stream).Close()
 
@@ -285,25 +323,25 @@ func (a *MyAssistant) throwawayQuestion(text string) error {
 	err = (*a.speech).Play(context.Background(), trimSentence)
 	if err != nil {
 		klog.V(1).Infof("stream.Stream failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	klog.V(4).Infof("throwawayQuestion succeeded. text: %s\n", trimSentence)
-	return nil
+	return trimSentence, nil
 }
 
-func (a *MyAssistant) activetaskQuestion(text string) error {
+func (a *MyAssistant) activetaskQuestion(text string) (string, error) {
 	text = strings.TrimSpace(text)
 
 	if a.activeTask == nil {
 		klog.V(1).Infof("personas.Query failed\n")
-		return ErrNoActiveTask
+		return "", ErrNoActiveTask
 	}
 
 	stream, err := (*a.activeTask).Query(context.Background(), text)
 	if err != nil {
 		klog.V(1).Infof("personas.Query failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	// convert stream to string
@@ -312,7 +350,7 @@ func (a *MyAssistant) activetaskQuestion(text string) error {
 	err = (*stream).Stream(sb)
 	if err != nil {
 		klog.V(1).Infof("stream.Stream failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 	(*stream).Close()
 
@@ -321,9 +359,9 @@ func (a *MyAssistant) activetaskQuestion(text string) error {
 	err = (*a.speech).Play(context.Background(), trimSentence)
 	if err != nil {
 		klog.V(1).Infof("stream.Stream failed. Err: %v\n", err)
-		return err
+		return "", err
 	}
 
 	klog.V(4).Infof("throwawayQuestion succeeded. text: %s\n", trimSentence)
-	return nil
+	return trimSentence, nil
 }
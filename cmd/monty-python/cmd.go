@@ -6,12 +6,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 
 	assistant "github.com/dvonthenen/open-virtual-assistant/pkg/assistant"
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
 	initlib "github.com/dvonthenen/open-virtual-assistant/pkg/init"
+	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
 
 	assistantimpl "github.com/dvonthenen/open-virtual-assistant/cmd/monty-python/impl"
 )
@@ -24,6 +26,18 @@ func main() {
 		LogLevel: initlib.LogLevelStandard, // LogLevelStandard / LogLevelFull / LogLevelTrace / LogLevelVerbose
 	})
 
+	/*
+		Subcommands
+	*/
+	if flag.Arg(0) == "devices" {
+		err := microphone.PrintDevices(os.Stdout)
+		if err != nil {
+			fmt.Printf("microphone.PrintDevices failed. Err: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	/*
 		Assistant
 	*/
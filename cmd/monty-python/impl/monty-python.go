@@ -13,7 +13,9 @@ import (
 	matchr "github.com/antzucaro/matchr"
 	klog "k8s.io/klog/v2"
 
+	ainterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
 )
 
 // handles question...
@@ -91,7 +93,18 @@ func (a *MyAssistant) SetSpeech(s *interfaces.Speech) {
 	a.speech = s
 }
 
+// TriggeredTranscript implements ainterfaces.TriggerCallback, triggered by any of the questions
+func (a *MyAssistant) TriggeredTranscript(t *tinterfaces.Transcript) (*ainterfaces.Reply, error) {
+	return a.respond(t.Text)
+}
+
 func (a *MyAssistant) Response(text string) error {
+	_, err := a.respond(text)
+	return err
+}
+
+// respond answers the first question text matches
+func (a *MyAssistant) respond(text string) (*ainterfaces.Reply, error) {
 	text = strings.ToLower(text)
 	klog.V(5).Infof("text: %s\n", text)
 
@@ -109,21 +122,23 @@ func (a *MyAssistant) Response(text string) error {
 
 				klog.V(2).Infof("Heard:\nMATCH (%f): %s = %s\n\n", percent, key, text)
 
+				reply := &ainterfaces.Reply{Triggered: true}
 				if a.speech == nil {
 					klog.V(2).Infof("Unable to play reply audio: a.speech is nil\n")
-					return ErrTextToSpeectInvalid
+					return reply, ErrTextToSpeectInvalid
 				}
 
-				err := (*a.speech).Play(context.Background(), triggers.callback())
+				reply.Text = triggers.callback()
+				err := (*a.speech).Play(context.Background(), reply.Text)
 				if err != nil {
 					klog.V(1).Infof("speech.Play failed. Err: %v\n", err)
 				}
 
 				// exit on first match!
-				return nil
+				return reply, nil
 			}
 		}
 	}
 
-	return nil
+	return &ainterfaces.Reply{}, nil
 }
@@ -7,17 +7,28 @@ package assistant
 import (
 	"context"
 	"os"
+	"strings"
 
 	klog "k8s.io/klog/v2"
 
 	ainterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
 	sinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
 	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
 
+	file "github.com/dvonthenen/open-virtual-assistant/pkg/audio/file"
+	recorder "github.com/dvonthenen/open-virtual-assistant/pkg/audio/recorder"
+	vad "github.com/dvonthenen/open-virtual-assistant/pkg/audio/vad"
+	journal "github.com/dvonthenen/open-virtual-assistant/pkg/journal"
 	speech "github.com/dvonthenen/open-virtual-assistant/pkg/speech"
 	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
-	dgtranscriber "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/deepgram"
-	gtranscriber "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/google"
+	failover "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/failover"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+
+	// built-in transcribers
+	_ "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/deepgram"
+	_ "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/fake"
+	_ "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/google"
 )
 
 func New(assistantImpl *ainterfaces.AssistantImpl, opts *AssistantOptions) (*Assistant, error) {
@@ -26,10 +37,55 @@ func New(assistantImpl *ainterfaces.AssistantImpl, opts *AssistantOptions) (*Ass
 	if opts == nil {
 		opts = &AssistantOptions{}
 	}
+	if opts.DeviceLostMessage == "" {
+		opts.DeviceLostMessage = ainterfaces.DefaultDeviceLostMessage
+	}
+	if v := os.Getenv("ASSISTANT_INPUT_DEVICE"); v != "" && opts.InputDevice == "" {
+		klog.V(2).Infof("ASSISTANT_INPUT_DEVICE found\n")
+		opts.InputDevice = v
+	}
+	if v := os.Getenv("ASSISTANT_RECORDING_DIR"); v != "" && opts.RecordingDirectory == "" {
+		klog.V(2).Infof("ASSISTANT_RECORDING_DIR found\n")
+		opts.RecordingDirectory = v
+	}
+	if v := os.Getenv("ASSISTANT_JOURNAL_DIR"); v != "" && opts.JournalDirectory == "" {
+		klog.V(2).Infof("ASSISTANT_JOURNAL_DIR found\n")
+		opts.JournalDirectory = v
+	}
+
+	// recognize the language we speak unless told otherwise
+	transcriberLanguage := opts.TranscriberLanguage
+	if transcriberLanguage == "" {
+		transcriberLanguage = opts.LanguageCode
+	}
 
 	// transcriber callback
-	var callback tinterfaces.ResponseCallback
-	callback = *assistantImpl
+	impl := &implCallback{
+		assistantImpl: assistantImpl,
+		splitChannels: opts.SplitChannels,
+	}
+
+	var callback tinterfaces.TranscriptCallback
+	callback = impl
+
+	// journal what was heard and said?
+	var transcripts *transcriptJournal
+	if opts.JournalDirectory != "" {
+		sessionJournal, errJournal := journal.New(journal.JournalConfig{
+			Directory: opts.JournalDirectory,
+		})
+		if errJournal != nil {
+			klog.V(1).Infof("journal.New failed. Err: %v\n", errJournal)
+			return nil, errJournal
+		}
+		klog.V(2).Infof("Journaling session %s to %s\n", sessionJournal.SessionID(), opts.JournalDirectory)
+
+		transcripts = &transcriptJournal{
+			journal:  sessionJournal,
+			callback: impl,
+		}
+		callback = transcripts
+	}
 
 	// assistant
 	assistant := &Assistant{
@@ -38,13 +94,33 @@ func New(assistantImpl *ainterfaces.AssistantImpl, opts *AssistantOptions) (*Ass
 			LanguageCode: opts.LanguageCode,
 		},
 		transcriberOptions: &config.TranscribeOptions{
-			InputChannels: opts.InputChannels,
-			SamplingRate:  opts.SamplingRate,
-			Callback:      &callback,
+			InputChannels:        opts.InputChannels,
+			SamplingRate:         opts.SamplingRate,
+			InputDevice:          opts.InputDevice,
+			Source:               opts.Source,
+			VoiceActivity:        opts.VoiceActivity,
+			PreRoll:              opts.PreRoll,
+			Language:             transcriberLanguage,
+			AlternativeLanguages: opts.AlternativeLanguages,
+			DetectLanguage:       opts.DetectLanguage,
+			Segmentation:         opts.Segmentation,
+			Adaptation:           opts.Adaptation,
+			PostProcess:          opts.PostProcess,
+			TranscriptCallback:   &callback,
 		},
 		assistantImpl: assistantImpl,
 	}
 
+	// live captions?
+	if partial, ok := (*assistantImpl).(tinterfaces.PartialTranscriptCallback); ok {
+		klog.V(3).Infof("Partial transcripts enabled\n")
+		assistant.transcriberOptions.PartialCallback = &partial
+	}
+
+	if utteranceEnd, ok := (*assistantImpl).(tinterfaces.UtteranceEndCallback); ok {
+		assistant.transcriberOptions.UtteranceEndCallback = &utteranceEnd
+	}
+
 	// text-to-speech client
 	speech, err := speech.New(ctx, assistant.speechOptions)
 	if err != nil {
@@ -52,48 +128,168 @@ func New(assistantImpl *ainterfaces.AssistantImpl, opts *AssistantOptions) (*Ass
 		return nil, err
 	}
 
-	// which transcriber?
-	var transcriberStr string
-	if v := os.Getenv("ASSISTANT_TRANSCRIBER"); v != "" {
-		klog.V(2).Infof("ASSISTANT_TRANSCRIBER found\n")
-		transcriberStr = v
+	// speech output and barge-in
+	var playback sinterfaces.Speech
+	playback = speech
+
+	if opts.BargeIn != ainterfaces.BargeInDisabled {
+		klog.V(3).Infof("Barge-in enabled. Mode: %d\n", opts.BargeIn)
+
+		assistant.bargeIn = newBargeIn(callback, speech, opts)
+		playback = assistant.bargeIn
+
+		var bargeInCallback tinterfaces.TranscriptCallback
+		bargeInCallback = assistant.bargeIn
+		assistant.transcriberOptions.TranscriptCallback = &bargeInCallback
+
+		if opts.BargeIn == ainterfaces.BargeInSpeech {
+			vadCfg := vad.VADConfig{}
+			if opts.VoiceActivity != nil {
+				vadCfg = *opts.VoiceActivity
+			}
+			assistant.bargeIn.vadCallback = vadCfg.Callback
+
+			var vadCallback audio.VoiceActivityCallback
+			vadCallback = assistant.bargeIn
+			vadCfg.Callback = &vadCallback
+			assistant.transcriberOptions.VoiceActivity = &vadCfg
+		}
 	}
 
-	// get the transcriber
-	var transcriber Transcriber
+	// microphone status
+	var status audio.DeviceStatusCallback
+	status = &deviceStatus{
+		options:       opts,
+		assistantImpl: assistantImpl,
+		speech:        playback,
+	}
+	assistant.transcriberOptions.DeviceStatus = &status
+
+	// transcriber connection status
+	var connection tinterfaces.ConnectionStatusCallback
+	connection = &connectionStatus{
+		options:       opts,
+		assistantImpl: assistantImpl,
+	}
+	assistant.transcriberOptions.ConnectionStatus = &connection
+
+	// replay audio from a file instead of the mic?
+	if v := os.Getenv("ASSISTANT_AUDIO_FILE"); v != "" && opts.Source == nil {
+		klog.V(2).Infof("ASSISTANT_AUDIO_FILE found\n")
+
+		fileSource, errFile := file.New(file.FileConfig{
+			FilePath:      v,
+			InputChannels: opts.InputChannels,
+			SamplingRate:  opts.SamplingRate,
+			RealTime:      true,
+		})
+		if errFile != nil {
+			klog.V(1).Infof("file.New failed. Err: %v\n", errFile)
+			return nil, errFile
+		}
+
+		var source audio.AudioSource
+		source = fileSource
 
-	switch transcriberStr {
-	case ainterfaces.DEEPGRAM_TRANSCRIBER:
-		transcribe, errTranscribe := dgtranscriber.New(ctx, assistant.transcriberOptions)
-		if errTranscribe != nil {
-			klog.V(1).Infof("dgtranscriber.New failed. Err: %v\n", errTranscribe)
-			return nil, errTranscribe
+		assistant.transcriberOptions.Source = &source
+		assistant.transcriberOptions.InputChannels = fileSource.InputChannels()
+		assistant.transcriberOptions.SamplingRate = fileSource.SamplingRate()
+	}
+
+	// record the session?
+	if opts.RecordingDirectory != "" {
+		// recordings and journal entries share the session ID
+		sessionID := ""
+		if transcripts != nil {
+			sessionID = transcripts.journal.SessionID()
 		}
-		transcriber = transcribe
-	case ainterfaces.GOOGLE_TRANSCRIBER:
-		fallthrough
-	default:
-		transcribe, errTranscribe := gtranscriber.New(ctx, assistant.transcriberOptions)
-		if errTranscribe != nil {
-			klog.V(1).Infof("gtranscriber.New failed. Err: %v\n", errTranscribe)
-			return nil, errTranscribe
+
+		sessionRecorder, errRecorder := recorder.New(recorder.RecorderConfig{
+			Directory:     opts.RecordingDirectory,
+			SessionID:     sessionID,
+			InputChannels: assistant.transcriberOptions.InputChannels,
+			SamplingRate:  assistant.transcriberOptions.SamplingRate,
+		})
+		if errRecorder != nil {
+			klog.V(1).Infof("recorder.New failed. Err: %v\n", errRecorder)
+			return nil, errRecorder
 		}
-		transcriber = transcribe
+		klog.V(2).Infof("Recording session %s to %s\n", sessionRecorder.SessionID(), opts.RecordingDirectory)
+
+		assistant.recorders = append(assistant.recorders, sessionRecorder)
+		assistant.transcriberOptions.Recorder = sessionRecorder
 	}
 
-	// housekeeping
-	var playback sinterfaces.Speech
-	playback = speech
+	// get the transcriber, one per channel when splitting
+	backends := transcriberBackends(opts)
+
+	var transcriber Transcriber
+	if opts.SplitChannels && assistant.transcriberOptions.InputChannels > 1 {
+		transcriber, err = assistant.newChannelTranscribers(ctx, backends, opts)
+	} else {
+		transcriber, err = newTranscriber(ctx, backends, opts, assistant.transcriberOptions)
+	}
+	if err != nil {
+		klog.V(1).Infof("newTranscriber failed. Err: %v\n", err)
+		return nil, err
+	}
 
+	// housekeeping
 	assistant.speech = speech
 	assistant.transcriber = &transcriber
 	(*assistantImpl).SetSpeech(&playback)
 	assistant.assistantImpl = assistantImpl
+	if transcripts != nil {
+		assistant.journal = transcripts.journal
+	}
 
 	return assistant, nil
 }
 
+// transcriberBackends which transcribers to use, in failover order
+func transcriberBackends(opts *AssistantOptions) []string {
+	backends := opts.Transcribers
+	if len(backends) == 0 && opts.Transcriber != "" {
+		backends = []string{opts.Transcriber}
+	}
+	if v := os.Getenv("ASSISTANT_TRANSCRIBER"); v != "" && len(backends) == 0 {
+		klog.V(2).Infof("ASSISTANT_TRANSCRIBER found\n")
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				backends = append(backends, name)
+			}
+		}
+	}
+	if len(backends) == 0 {
+		backends = []string{tinterfaces.DEFAULT_TRANSCRIBER}
+	}
+	return backends
+}
+
+// newTranscriber creates the backend, failing over between them when there is more than one
+func newTranscriber(ctx context.Context, backends []string, opts *AssistantOptions, transcriberOpts *config.TranscribeOptions) (Transcriber, error) {
+	if len(backends) == 1 {
+		return registry.New(ctx, backends[0], transcriberOpts)
+	}
+
+	klog.V(3).Infof("Transcriber failover order: %s\n", strings.Join(backends, ", "))
+	transcriber, err := failover.New(ctx, transcriberOpts, failover.FailoverConfig{
+		Backends:      backends,
+		FailoverAfter: opts.FailoverAfter,
+		SwitchBack:    opts.SwitchBack,
+	})
+	if err != nil {
+		klog.V(1).Infof("failover.New failed. Err: %v\n", err)
+		return nil, err
+	}
+	return transcriber, nil
+}
+
 func (a *Assistant) Start() error {
+	if a.bargeIn != nil {
+		a.bargeIn.Start()
+	}
+
 	err := (*a.transcriber).Start()
 	if err != nil {
 		klog.V(1).Infof("transcriber.Start failed. Err: %v\n", err)
@@ -117,10 +313,27 @@ func (a *Assistant) Speak(text string) error {
 	return nil
 }
 
+// Interrupt stops the assistant if it is currently speaking. Requires barge-in to be enabled.
+func (a *Assistant) Interrupt() {
+	if a.bargeIn != nil {
+		a.bargeIn.Interrupt()
+	}
+}
+
 func (a *Assistant) Stop() error {
 	err := (*a.transcriber).Stop()
 	if err != nil {
 		klog.V(1).Infof("transcriber.Stop failed. Err: %v\n", err)
 	}
+
+	if a.bargeIn != nil {
+		a.bargeIn.Stop()
+	}
+	for _, r := range a.recorders {
+		r.Close()
+	}
+	if a.journal != nil {
+		a.journal.Close()
+	}
 	return err
 }
@@ -0,0 +1,164 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	"context"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+
+	ainterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
+	sinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+const (
+	// pending utterances waiting on the assistant implementation
+	bargeInQueueSize int = 16
+)
+
+func newBargeIn(callback tinterfaces.TranscriptCallback, speech sinterfaces.Speech, opts *AssistantOptions) *bargeIn {
+	stopPhrases := opts.StopPhrases
+	if len(stopPhrases) == 0 {
+		stopPhrases = ainterfaces.DefaultStopPhrases
+	}
+
+	b := &bargeIn{
+		mode:        opts.BargeIn,
+		stopPhrases: make(map[string]bool),
+		callback:    callback,
+		speech:      speech,
+		responses:   make(chan *tinterfaces.Transcript, bargeInQueueSize),
+		stopChan:    make(chan struct{}),
+	}
+	for _, phrase := range stopPhrases {
+		b.stopPhrases[normalizePhrase(phrase)] = true
+	}
+
+	return b
+}
+
+// Start processes transcriptions on a separate goroutine so they never block
+// the transcriber and playback can be interrupted
+func (b *bargeIn) Start() {
+	go func() {
+		for {
+			select {
+			case <-b.stopChan:
+				return
+			case transcript := <-b.responses:
+				err := b.callback.Transcript(transcript)
+				if err != nil {
+					klog.V(1).Infof("callback.Transcript failed. Err: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop interrupts any playback and stops processing transcriptions
+func (b *bargeIn) Stop() {
+	b.Interrupt()
+	close(b.stopChan)
+}
+
+// Interrupt cancels the current playback, if any
+func (b *bargeIn) Interrupt() {
+	b.mu.Lock()
+	cancel := b.cancel
+	b.mu.Unlock()
+
+	if cancel != nil {
+		klog.V(2).Infof("Barge-in: interrupting playback\n")
+		cancel()
+	}
+}
+
+// Play implements sinterfaces.Speech by playing the text in a way that can be interrupted
+func (b *bargeIn) Play(ctx context.Context, text string) error {
+	playCtx, cancel := context.WithCancel(ctx)
+
+	b.mu.Lock()
+	b.cancel = cancel
+	b.mu.Unlock()
+
+	err := b.speech.Play(playCtx, text)
+
+	b.mu.Lock()
+	b.cancel = nil
+	b.mu.Unlock()
+	cancel()
+
+	// being interrupted is not a failure
+	if err != nil && playCtx.Err() != nil && ctx.Err() == nil {
+		klog.V(3).Infof("Playback interrupted by barge-in\n")
+		return nil
+	}
+
+	return err
+}
+
+// Transcript implements tinterfaces.TranscriptCallback
+func (b *bargeIn) Transcript(t *tinterfaces.Transcript) error {
+	text := t.Text
+	if b.isPlaying() {
+		if b.stopPhrases[normalizePhrase(text)] {
+			klog.V(2).Infof("Barge-in: stop phrase heard = %s\n", text)
+			b.Interrupt()
+			return nil
+		}
+
+		// most likely the assistant hearing itself
+		klog.V(4).Infof("Barge-in: ignoring while speaking = %s\n", text)
+		return nil
+	}
+
+	select {
+	case b.responses <- t:
+	default:
+		klog.V(1).Infof("Barge-in: response queue full. Dropping = %s\n", text)
+	}
+
+	return nil
+}
+
+// SpeechStart implements audio.VoiceActivityCallback
+func (b *bargeIn) SpeechStart() error {
+	if b.mode == ainterfaces.BargeInSpeech && b.isPlaying() {
+		klog.V(2).Infof("Barge-in: speech detected\n")
+		b.Interrupt()
+	}
+
+	if b.vadCallback != nil {
+		return (*b.vadCallback).SpeechStart()
+	}
+	return nil
+}
+
+// SpeechEnd implements audio.VoiceActivityCallback
+func (b *bargeIn) SpeechEnd() error {
+	if b.vadCallback != nil {
+		return (*b.vadCallback).SpeechEnd()
+	}
+	return nil
+}
+
+func (b *bargeIn) isPlaying() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cancel != nil
+}
+
+func normalizePhrase(text string) string {
+	text = strings.ToLower(text)
+	text = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(".,!?;:", r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	ainterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// Transcript implements tinterfaces.TranscriptCallback by handing the transcript to
+// the richest callback the assistant implementation supports
+func (r *implCallback) Transcript(t *tinterfaces.Transcript) error {
+	_, err := r.respond(t)
+	return err
+}
+
+// respond delivers the transcript and returns what the implementation made of it.
+// Implementations without ainterfaces.TriggerCallback are never triggered.
+func (r *implCallback) respond(t *tinterfaces.Transcript) (*ainterfaces.Reply, error) {
+	impl := *r.assistantImpl
+
+	if trigger, ok := impl.(ainterfaces.TriggerCallback); ok {
+		reply, err := trigger.TriggeredTranscript(t)
+		if reply == nil {
+			reply = &ainterfaces.Reply{}
+		}
+		return reply, err
+	}
+
+	var err error
+	if transcriptAware, ok := impl.(tinterfaces.TranscriptCallback); ok {
+		err = transcriptAware.Transcript(t)
+	} else if channelAware, ok := impl.(tinterfaces.ChannelResponseCallback); ok && r.splitChannels {
+		err = channelAware.ChannelResponse(t.Channel, t.Text)
+	} else {
+		err = impl.Response(t.Text)
+	}
+	return &ainterfaces.Reply{}, err
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	"context"
+	"fmt"
+
+	klog "k8s.io/klog/v2"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	recorder "github.com/dvonthenen/open-virtual-assistant/pkg/audio/recorder"
+	splitter "github.com/dvonthenen/open-virtual-assistant/pkg/audio/splitter"
+	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// newChannelTranscribers splits the input into mono streams with a transcriber each
+func (a *Assistant) newChannelTranscribers(ctx context.Context, backends []string, opts *AssistantOptions) (*channelTranscriber, error) {
+	channels := a.transcriberOptions.InputChannels
+	klog.V(3).Infof("Splitting %d channels into separate transcribers\n", channels)
+
+	multi := &channelTranscriber{}
+
+	// undo whatever was built when a later channel fails. the splitter was never
+	// started so stopping the channel transcribers leaves the mic alone.
+	var mic *microphone.Microphone
+	var recorders []*recorder.Recorder
+	cleanup := func() {
+		for i, transcriber := range multi.transcribers {
+			err := transcriber.Stop()
+			if err != nil {
+				klog.V(1).Infof("transcriber.Stop for channel %d failed. Err: %v\n", i, err)
+			}
+		}
+		if multi.ownsMic {
+			mic.Stop()
+			microphone.Teardown()
+		}
+		for _, r := range recorders {
+			r.Close()
+		}
+	}
+
+	// audio source, fallback to the default mic
+	var source audio.AudioSource
+	if a.transcriberOptions.Source != nil {
+		source = *a.transcriberOptions.Source
+	} else {
+		microphone.Initialize()
+
+		var err error
+		mic, err = microphone.New(microphone.AudioConfig{
+			InputChannels: channels,
+			SamplingRate:  float32(a.transcriberOptions.SamplingRate),
+			InputDevice:   a.transcriberOptions.InputDevice,
+			VoiceActivity: a.transcriberOptions.VoiceActivity,
+			PreRoll:       a.transcriberOptions.PreRoll,
+			DeviceStatus:  a.transcriberOptions.DeviceStatus,
+		})
+		if err != nil {
+			klog.V(1).Infof("microphone.New failed. Err: %v\n", err)
+			microphone.Teardown()
+			return nil, err
+		}
+		source = mic
+		multi.ownsMic = true
+	}
+
+	split := splitter.New(source, channels)
+
+	for i := 0; i < channels; i++ {
+		channelOpts := *a.transcriberOptions
+		channelOpts.InputChannels = 1
+
+		var channelSource audio.AudioSource
+		channelSource = split.Channel(i)
+		channelOpts.Source = &channelSource
+
+		tagger := &channelCallback{
+			channel:      i,
+			callback:     a.transcriberOptions.TranscriptCallback,
+			partial:      a.transcriberOptions.PartialCallback,
+			utteranceEnd: a.transcriberOptions.UtteranceEndCallback,
+		}
+
+		var callback tinterfaces.TranscriptCallback
+		callback = tagger
+		channelOpts.TranscriptCallback = &callback
+
+		if a.transcriberOptions.PartialCallback != nil {
+			var partial tinterfaces.PartialTranscriptCallback
+			partial = tagger
+			channelOpts.PartialCallback = &partial
+		}
+
+		if a.transcriberOptions.UtteranceEndCallback != nil {
+			var utteranceEnd tinterfaces.UtteranceEndCallback
+			utteranceEnd = tagger
+			channelOpts.UtteranceEndCallback = &utteranceEnd
+		}
+
+		// mono recording per channel
+		if a.transcriberOptions.Recorder != nil {
+			channelRecorder, err := recorder.New(recorder.RecorderConfig{
+				Directory:     opts.RecordingDirectory,
+				SessionID:     fmt.Sprintf("%s-ch%d", a.transcriberOptions.Recorder.SessionID(), i),
+				InputChannels: 1,
+				SamplingRate:  a.transcriberOptions.SamplingRate,
+			})
+			if err != nil {
+				klog.V(1).Infof("recorder.New failed. Err: %v\n", err)
+				cleanup()
+				return nil, err
+			}
+			recorders = append(recorders, channelRecorder)
+			channelOpts.Recorder = channelRecorder
+		}
+
+		transcriber, err := newTranscriber(ctx, backends, opts, &channelOpts)
+		if err != nil {
+			klog.V(1).Infof("newTranscriber for channel %d failed. Err: %v\n", i, err)
+			cleanup()
+			return nil, err
+		}
+		multi.transcribers = append(multi.transcribers, transcriber)
+	}
+	a.recorders = append(a.recorders, recorders...)
+
+	return multi, nil
+}
+
+// Start starts every channel's transcriber
+func (c *channelTranscriber) Start() error {
+	for i, transcriber := range c.transcribers {
+		err := transcriber.Start()
+		if err != nil {
+			klog.V(1).Infof("transcriber.Start for channel %d failed. Err: %v\n", i, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops every channel's transcriber
+func (c *channelTranscriber) Stop() error {
+	var firstErr error
+	for i, transcriber := range c.transcribers {
+		err := transcriber.Stop()
+		if err != nil {
+			klog.V(1).Infof("transcriber.Stop for channel %d failed. Err: %v\n", i, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if c.ownsMic {
+		klog.V(4).Infof("Calling microphone.Teardown...")
+		microphone.Teardown()
+	}
+
+	return firstErr
+}
+
+// Transcript implements tinterfaces.TranscriptCallback
+func (c *channelCallback) Transcript(t *tinterfaces.Transcript) error {
+	t.Channel = c.channel
+	return (*c.callback).Transcript(t)
+}
+
+// ConnectionState implements tinterfaces.ConnectionReporter by reporting the worst
+// state across the channels
+func (c *channelTranscriber) ConnectionState() tinterfaces.ConnectionState {
+	state := tinterfaces.ConnectionConnected
+	for _, transcriber := range c.transcribers {
+		reporter, ok := transcriber.(tinterfaces.ConnectionReporter)
+		if !ok {
+			continue
+		}
+
+		switch reporter.ConnectionState() {
+		case tinterfaces.ConnectionReconnecting:
+			return tinterfaces.ConnectionReconnecting
+		case tinterfaces.ConnectionDisconnected:
+			state = tinterfaces.ConnectionDisconnected
+		}
+	}
+	return state
+}
+
+// PartialTranscript implements tinterfaces.PartialTranscriptCallback
+func (c *channelCallback) PartialTranscript(t *tinterfaces.Transcript) error {
+	t.Channel = c.channel
+	return (*c.partial).PartialTranscript(t)
+}
+
+// UtteranceEnd implements tinterfaces.UtteranceEndCallback
+func (c *channelCallback) UtteranceEnd(utteranceID string, channel int) error {
+	return (*c.utteranceEnd).UtteranceEnd(utteranceID, c.channel)
+}
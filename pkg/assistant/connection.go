@@ -0,0 +1,93 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// ConnectionLost implements tinterfaces.ConnectionStatusCallback
+func (c *connectionStatus) ConnectionLost(backend string, err error) error {
+	klog.V(1).Infof("Assistant lost the %s transcriber. Err: %v\n", backend, err)
+
+	if c.options.ConnectionStatus != nil {
+		errCallback := (*c.options.ConnectionStatus).ConnectionLost(backend, err)
+		if errCallback != nil {
+			klog.V(1).Infof("ConnectionStatus.ConnectionLost failed. Err: %v\n", errCallback)
+		}
+	}
+	if impl, ok := (*c.assistantImpl).(tinterfaces.ConnectionStatusCallback); ok {
+		errCallback := impl.ConnectionLost(backend, err)
+		if errCallback != nil {
+			klog.V(1).Infof("assistantImpl.ConnectionLost failed. Err: %v\n", errCallback)
+		}
+	}
+
+	return nil
+}
+
+// ConnectionRestored implements tinterfaces.ConnectionStatusCallback
+func (c *connectionStatus) ConnectionRestored(backend string, downtime time.Duration) error {
+	klog.V(1).Infof("Assistant reconnected the %s transcriber after %v\n", backend, downtime)
+
+	if c.options.ConnectionStatus != nil {
+		err := (*c.options.ConnectionStatus).ConnectionRestored(backend, downtime)
+		if err != nil {
+			klog.V(1).Infof("ConnectionStatus.ConnectionRestored failed. Err: %v\n", err)
+		}
+	}
+	if impl, ok := (*c.assistantImpl).(tinterfaces.ConnectionStatusCallback); ok {
+		err := impl.ConnectionRestored(backend, downtime)
+		if err != nil {
+			klog.V(1).Infof("assistantImpl.ConnectionRestored failed. Err: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// BackendSwitched implements tinterfaces.BackendSwitchCallback, passing the switch on
+// as ConnectionRestored for the new backend to callbacks which do not implement it
+func (c *connectionStatus) BackendSwitched(from, to string, downtime time.Duration) error {
+	klog.V(1).Infof("Assistant switched from the %s to the %s transcriber after %v\n", from, to, downtime)
+
+	if c.options.ConnectionStatus != nil {
+		var err error
+		if switcher, ok := (*c.options.ConnectionStatus).(tinterfaces.BackendSwitchCallback); ok {
+			err = switcher.BackendSwitched(from, to, downtime)
+		} else {
+			err = (*c.options.ConnectionStatus).ConnectionRestored(to, downtime)
+		}
+		if err != nil {
+			klog.V(1).Infof("ConnectionStatus.BackendSwitched failed. Err: %v\n", err)
+		}
+	}
+	if impl, ok := (*c.assistantImpl).(tinterfaces.BackendSwitchCallback); ok {
+		err := impl.BackendSwitched(from, to, downtime)
+		if err != nil {
+			klog.V(1).Infof("assistantImpl.BackendSwitched failed. Err: %v\n", err)
+		}
+	} else if impl, ok := (*c.assistantImpl).(tinterfaces.ConnectionStatusCallback); ok {
+		err := impl.ConnectionRestored(to, downtime)
+		if err != nil {
+			klog.V(1).Infof("assistantImpl.ConnectionRestored failed. Err: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// ConnectionState returns the state of the transcriber's backend connection. Transcribers
+// which do not report one are assumed to be connected.
+func (a *Assistant) ConnectionState() tinterfaces.ConnectionState {
+	if reporter, ok := (*a.transcriber).(tinterfaces.ConnectionReporter); ok {
+		return reporter.ConnectionState()
+	}
+	return tinterfaces.ConnectionConnected
+}
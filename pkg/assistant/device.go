@@ -0,0 +1,64 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	"context"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+)
+
+// DeviceLost implements audio.DeviceStatusCallback
+func (d *deviceStatus) DeviceLost(err error) error {
+	klog.V(1).Infof("Assistant lost the microphone. Err: %v\n", err)
+
+	if d.options.DeviceStatus != nil {
+		errCallback := (*d.options.DeviceStatus).DeviceLost(err)
+		if errCallback != nil {
+			klog.V(1).Infof("DeviceStatus.DeviceLost failed. Err: %v\n", errCallback)
+		}
+	}
+	if impl, ok := (*d.assistantImpl).(audio.DeviceStatusCallback); ok {
+		errCallback := impl.DeviceLost(err)
+		if errCallback != nil {
+			klog.V(1).Infof("assistantImpl.DeviceLost failed. Err: %v\n", errCallback)
+		}
+	}
+
+	return nil
+}
+
+// DeviceRecovered implements audio.DeviceStatusCallback
+func (d *deviceStatus) DeviceRecovered(downtime time.Duration) error {
+	klog.V(1).Infof("Assistant recovered the microphone after %v\n", downtime)
+
+	if d.options.DeviceStatus != nil {
+		err := (*d.options.DeviceStatus).DeviceRecovered(downtime)
+		if err != nil {
+			klog.V(1).Infof("DeviceStatus.DeviceRecovered failed. Err: %v\n", err)
+		}
+	}
+	if impl, ok := (*d.assistantImpl).(audio.DeviceStatusCallback); ok {
+		err := impl.DeviceRecovered(downtime)
+		if err != nil {
+			klog.V(1).Infof("assistantImpl.DeviceRecovered failed. Err: %v\n", err)
+		}
+	}
+
+	// called from the capture loop so do not block it while speaking
+	if d.options.AnnounceDeviceLoss {
+		go func() {
+			err := d.speech.Play(context.Background(), d.options.DeviceLostMessage)
+			if err != nil {
+				klog.V(1).Infof("speech.Play failed. Err: %v\n", err)
+			}
+		}()
+	}
+
+	return nil
+}
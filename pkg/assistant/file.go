@@ -0,0 +1,64 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	"context"
+
+	klog "k8s.io/klog/v2"
+
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+)
+
+// TranscribeFile transcribes a recording, like a recorded session or a voicemail, with
+// the same transcriber, language, phrases and post-processing the live assistant
+// would use. Backends are tried in failover order until one succeeds.
+func TranscribeFile(ctx context.Context, filePath string, opts *AssistantOptions) (*tinterfaces.FileTranscript, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts == nil {
+		opts = &AssistantOptions{}
+	}
+
+	language := opts.TranscriberLanguage
+	if language == "" {
+		language = opts.LanguageCode
+	}
+
+	var lastErr error
+	for _, backend := range transcriberBackends(opts) {
+		transcriber, err := registry.NewFile(ctx, backend, &config.TranscribeOptions{
+			InputChannels:        opts.InputChannels,
+			SamplingRate:         opts.SamplingRate,
+			Language:             language,
+			AlternativeLanguages: opts.AlternativeLanguages,
+			DetectLanguage:       opts.DetectLanguage,
+			Segmentation:         opts.Segmentation,
+			Adaptation:           opts.Adaptation,
+			PostProcess:          opts.PostProcess,
+		})
+		if err != nil {
+			klog.V(1).Infof("registry.NewFile(%s) failed. Err: %v\n", backend, err)
+			lastErr = err
+			continue
+		}
+
+		transcript, err := transcriber.TranscribeFile(ctx, filePath)
+		transcriber.Close()
+		if err != nil {
+			klog.V(1).Infof("TranscribeFile(%s) failed. Err: %v\n", backend, err)
+			lastErr = err
+			continue
+		}
+
+		return transcript, nil
+	}
+
+	return nil, lastErr
+}
@@ -6,13 +6,37 @@ package interfaces
 
 import (
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
 )
 
 // constants...
 const (
 	// transcriber options
-	DEEPGRAM_TRANSCRIBER string = "deepgram"
-	GOOGLE_TRANSCRIBER   string = "google"
+	DEEPGRAM_TRANSCRIBER = tinterfaces.DEEPGRAM_TRANSCRIBER
+	GOOGLE_TRANSCRIBER   = tinterfaces.GOOGLE_TRANSCRIBER
+)
+
+// BargeInMode controls if and how the user can interrupt the assistant while it is speaking
+type BargeInMode int
+
+const (
+	// BargeInDisabled the mic is muted while the assistant responds
+	BargeInDisabled BargeInMode = iota
+	// BargeInKeyword saying one of the stop phrases interrupts playback
+	BargeInKeyword
+	// BargeInSpeech any detected speech interrupts playback. Requires voice activity detection
+	// and works best with headphones or echo cancellation since the assistant can hear itself.
+	BargeInSpeech
+)
+
+const (
+	// DefaultDeviceLostMessage spoken once a lost microphone comes back
+	DefaultDeviceLostMessage string = "Sorry, I lost my microphone for a moment. I can hear you again."
+)
+
+var (
+	// DefaultStopPhrases phrases which interrupt playback in barge-in mode
+	DefaultStopPhrases = []string{"stop", "kitt stop", "kit stop", "hey kitt stop", "stop talking", "be quiet"}
 )
 
 const (
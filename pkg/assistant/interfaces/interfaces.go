@@ -14,3 +14,9 @@ type AssistantImpl interface {
 
 	SetSpeech(s *speech.Speech)
 }
+
+// TriggerCallback can be implemented alongside ResponseCallback to report whether a
+// transcript was meant for the assistant and what it said back, which the journal records
+type TriggerCallback interface {
+	TriggeredTranscript(t *transcriber.Transcript) (*Reply, error)
+}
@@ -0,0 +1,14 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+// Reply what the assistant made of a transcript
+type Reply struct {
+	// Triggered the transcript was meant for the assistant
+	Triggered bool
+
+	// Text what the assistant said back, if anything
+	Text string
+}
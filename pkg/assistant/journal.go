@@ -0,0 +1,41 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assistant
+
+import (
+	klog "k8s.io/klog/v2"
+
+	journal "github.com/dvonthenen/open-virtual-assistant/pkg/journal"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// Transcript implements tinterfaces.TranscriptCallback. The reply is whatever the
+// assistant implementation says it answered this transcript with.
+func (j *transcriptJournal) Transcript(t *tinterfaces.Transcript) error {
+	reply, errCallback := j.callback.respond(t)
+
+	entry := &journal.Entry{
+		Timestamp:   t.Timestamp,
+		UtteranceID: t.UtteranceID,
+		Backend:     t.Backend,
+		Language:    t.Language,
+		Channel:     t.Channel,
+		Text:        t.Text,
+		Confidence:  t.Confidence,
+		LatencyMs:   t.Latency.Milliseconds(),
+		Triggered:   reply.Triggered,
+		Reply:       reply.Text,
+	}
+	if errCallback != nil {
+		entry.Error = errCallback.Error()
+	}
+
+	err := j.journal.Write(entry)
+	if err != nil {
+		klog.V(1).Infof("journal.Write failed. Err: %v\n", err)
+	}
+
+	return errCallback
+}
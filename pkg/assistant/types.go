@@ -5,23 +5,101 @@
 package assistant
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/assistant/interfaces"
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	recorder "github.com/dvonthenen/open-virtual-assistant/pkg/audio/recorder"
+	vad "github.com/dvonthenen/open-virtual-assistant/pkg/audio/vad"
+	journal "github.com/dvonthenen/open-virtual-assistant/pkg/journal"
 	speech "github.com/dvonthenen/open-virtual-assistant/pkg/speech"
+	sinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/speech/interfaces"
 	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	postprocess "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/postprocess"
 	texttospeechpb "google.golang.org/genproto/googleapis/cloud/texttospeech/v1"
 )
 
-// Transcriber interface
-type Transcriber interface {
-	Start() error
-	Stop() error
-}
+// Transcriber interface, kept for compatibility
+type Transcriber = tinterfaces.Transcriber
 
 // assistant implementation
 type AssistantOptions struct {
 	InputChannels int
 	SamplingRate  int
 
+	// Transcriber name of a registered transcriber backend. Falls back to the
+	// ASSISTANT_TRANSCRIBER environment variable and then Google.
+	Transcriber string
+
+	// Transcribers backends to fail over between, most preferred first. Takes precedence
+	// over Transcriber. ASSISTANT_TRANSCRIBER can also be a comma separated list.
+	Transcribers []string
+	// FailoverAfter how long a backend can be reconnecting before moving on
+	FailoverAfter time.Duration
+	// SwitchBack periodically retries the first backend after failing over
+	SwitchBack bool
+
+	// InputDevice selects the microphone by index or name substring
+	InputDevice string
+
+	// Source overrides the default microphone
+	Source *audio.AudioSource
+
+	// VoiceActivity enables voice activity detection on the default microphone
+	VoiceActivity *vad.VADConfig
+
+	// PreRoll how much audio the default microphone replays when listening resumes
+	PreRoll time.Duration
+
+	// DeviceStatus is notified when the default microphone is lost and recovered. The
+	// AssistantImpl is also notified if it implements audio.DeviceStatusCallback.
+	DeviceStatus *audio.DeviceStatusCallback
+	// AnnounceDeviceLoss speaks DeviceLostMessage once a lost microphone comes back
+	AnnounceDeviceLoss bool
+	DeviceLostMessage  string
+
+	// ConnectionStatus is notified when the transcriber loses and regains its backend.
+	// The AssistantImpl is also notified if it implements tinterfaces.ConnectionStatusCallback.
+	ConnectionStatus *tinterfaces.ConnectionStatusCallback
+
+	// SplitChannels transcribes each input channel separately. The AssistantImpl learns
+	// the channel if it implements ChannelResponseCallback.
+	SplitChannels bool
+
+	// TranscriberLanguage the language to recognize, falling back to LanguageCode.
+	// AlternativeLanguages and DetectLanguage let supported backends work out which
+	// language was spoken, which is reported on each transcript.
+	TranscriberLanguage  string
+	AlternativeLanguages []string
+	DetectLanguage       bool
+
+	// Segmentation decides when the user's turn is over. The AssistantImpl is told when
+	// it is if it implements tinterfaces.UtteranceEndCallback.
+	Segmentation *config.Segmentation
+
+	// Adaptation boosts phrases like the assistant's name. Each transcriber uses its
+	// own defaults when nil.
+	Adaptation *config.SpeechAdaptation
+
+	// PostProcess cleans up transcripts before the AssistantImpl sees them, for example
+	// replacing common misrecognitions of the assistant's name. Nil only lowercases.
+	PostProcess *postprocess.Pipeline
+
+	// RecordingDirectory saves every utterance as a WAV file with its transcript when set
+	RecordingDirectory string
+
+	// JournalDirectory appends every final transcript, and what the assistant said back,
+	// to a rotating JSON Lines journal when set
+	JournalDirectory string
+
+	// BargeIn lets the user interrupt the assistant while it is speaking. BargeInSpeech
+	// only applies to the default microphone.
+	BargeIn     interfaces.BargeInMode
+	StopPhrases []string
+
 	VoiceType    texttospeechpb.SsmlVoiceGender
 	LanguageCode string
 }
@@ -33,4 +111,63 @@ type Assistant struct {
 	transcriber   *Transcriber
 	speech        *speech.Client
 	assistantImpl *interfaces.AssistantImpl
+	bargeIn       *bargeIn
+	recorders     []*recorder.Recorder
+	journal       *journal.Journal
+}
+
+// channelTranscriber runs a transcriber per channel of a split source
+type channelTranscriber struct {
+	transcribers []Transcriber
+	ownsMic      bool
+}
+
+// channelCallback tags transcripts with the channel they were heard on
+type channelCallback struct {
+	channel      int
+	callback     *tinterfaces.TranscriptCallback
+	partial      *tinterfaces.PartialTranscriptCallback
+	utteranceEnd *tinterfaces.UtteranceEndCallback
+}
+
+// implCallback delivers transcripts to the assistant implementation
+type implCallback struct {
+	assistantImpl *interfaces.AssistantImpl
+	splitChannels bool
+}
+
+// transcriptJournal journals final transcripts on their way to the assistant
+// implementation, along with whatever it says back
+type transcriptJournal struct {
+	journal  *journal.Journal
+	callback *implCallback
+}
+
+// deviceStatus tells the assistant about the microphone coming and going
+type deviceStatus struct {
+	options       *AssistantOptions
+	assistantImpl *interfaces.AssistantImpl
+	speech        sinterfaces.Speech
+}
+
+// connectionStatus tells the assistant about the transcriber backend coming and going
+type connectionStatus struct {
+	options       *AssistantOptions
+	assistantImpl *interfaces.AssistantImpl
+}
+
+// bargeIn sits between the transcriber, assistant implementation and speech
+type bargeIn struct {
+	mode        interfaces.BargeInMode
+	stopPhrases map[string]bool
+
+	callback    tinterfaces.TranscriptCallback
+	speech      sinterfaces.Speech
+	vadCallback *audio.VoiceActivityCallback
+
+	// operational
+	responses chan *tinterfaces.Transcript
+	stopChan  chan struct{}
+	mu        sync.Mutex
+	cancel    context.CancelFunc
 }
@@ -0,0 +1,29 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"errors"
+)
+
+const (
+	// DefaultChunkSize number of int16 samples sent per write, same as the microphone
+	DefaultChunkSize int = 2048
+
+	// raw PCM defaults
+	DefaultInputChannels int = 1
+	DefaultSamplingRate  int = 16000
+)
+
+var (
+	// ErrInvalidWav the file has a RIFF/WAVE header but the contents are malformed
+	ErrInvalidWav = errors.New("invalid wav file")
+
+	// ErrUnsupportedFormat only 16-bit PCM wav files are supported
+	ErrUnsupportedFormat = errors.New("unsupported wav format, expected 16-bit PCM")
+
+	// ErrNoAudio the file does not hold any audio
+	ErrNoAudio = errors.New("file has no audio")
+)
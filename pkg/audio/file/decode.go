@@ -0,0 +1,143 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+	klog "k8s.io/klog/v2"
+)
+
+// Decode reads a WAV, MP3 or raw PCM file into little endian int16 PCM. Raw PCM
+// files are described by cfg, everything else by its header.
+func Decode(cfg FileConfig) (*PCM, error) {
+	if strings.EqualFold(filepath.Ext(cfg.FilePath), ".mp3") {
+		return decodeMP3(cfg.FilePath)
+	}
+
+	// no pacing, read it as fast as we can
+	cfg.RealTime = false
+	cfg.Loop = false
+
+	f, err := New(cfg)
+	if err != nil {
+		klog.V(1).Infof("file.New failed. Err: %v\n", err)
+		return nil, err
+	}
+	defer f.Stop()
+
+	var buf bytes.Buffer
+	err = f.Stream(&buf)
+	if err != nil {
+		klog.V(1).Infof("file.Stream failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	return &PCM{
+		Data:          buf.Bytes(),
+		InputChannels: f.InputChannels(),
+		SamplingRate:  f.SamplingRate(),
+	}, nil
+}
+
+func decodeMP3(filePath string) (*PCM, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		klog.V(1).Infof("os.Open failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	streamer, format, err := mp3.Decode(file)
+	if err != nil {
+		klog.V(1).Infof("mp3.Decode failed. Err: %v\n", err)
+		file.Close()
+		return nil, err
+	}
+	defer streamer.Close()
+
+	// re-encode as 16-bit, keeping the channels
+	pcmFormat := beep.Format{
+		SampleRate:  format.SampleRate,
+		NumChannels: format.NumChannels,
+		Precision:   2,
+	}
+
+	var buf bytes.Buffer
+	samples := make([][2]float64, DefaultChunkSize)
+	frame := make([]byte, pcmFormat.Width())
+	for {
+		n, ok := streamer.Stream(samples)
+		for _, sample := range samples[:n] {
+			pcmFormat.EncodeSigned(frame, sample)
+			buf.Write(frame)
+		}
+		if !ok {
+			break
+		}
+	}
+	if streamer.Err() != nil {
+		klog.V(1).Infof("mp3 stream failed. Err: %v\n", streamer.Err())
+		return nil, streamer.Err()
+	}
+
+	return &PCM{
+		Data:          buf.Bytes(),
+		InputChannels: format.NumChannels,
+		SamplingRate:  int(format.SampleRate),
+	}, nil
+}
+
+// Duration how long the audio plays for
+func (p *PCM) Duration() time.Duration {
+	bytesPerSecond := p.SamplingRate * p.InputChannels * 2
+	if bytesPerSecond == 0 {
+		return 0
+	}
+	return time.Duration(int64(len(p.Data)) * int64(time.Second) / int64(bytesPerSecond))
+}
+
+// Mono downmixes to a single channel at samplingRate, interpolating between samples
+func (p *PCM) Mono(samplingRate int) *PCM {
+	frameSize := p.InputChannels * 2
+	frames := len(p.Data) / frameSize
+
+	// average the channels
+	mono := make([]float64, frames)
+	for i := 0; i < frames; i++ {
+		var sum float64
+		for c := 0; c < p.InputChannels; c++ {
+			offset := i*frameSize + c*2
+			sum += float64(int16(binary.LittleEndian.Uint16(p.Data[offset:])))
+		}
+		mono[i] = sum / float64(p.InputChannels)
+	}
+
+	// resample
+	out := int(int64(frames) * int64(samplingRate) / int64(p.SamplingRate))
+	data := make([]byte, out*2)
+	step := float64(p.SamplingRate) / float64(samplingRate)
+	for i := 0; i < out; i++ {
+		pos := float64(i) * step
+		j := int(pos)
+		sample := mono[j]
+		if j+1 < frames {
+			sample += (mono[j+1] - mono[j]) * (pos - float64(j))
+		}
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(sample)))
+	}
+
+	return &PCM{
+		Data:          data,
+		InputChannels: 1,
+		SamplingRate:  samplingRate,
+	}
+}
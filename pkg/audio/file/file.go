@@ -0,0 +1,308 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// New creates a new audio source backed by a WAV or raw PCM file
+func New(cfg FileConfig) (*File, error) {
+	if cfg.InputChannels == 0 {
+		cfg.InputChannels = DefaultInputChannels
+	}
+	if cfg.SamplingRate == 0 {
+		cfg.SamplingRate = DefaultSamplingRate
+	}
+
+	file, err := os.Open(cfg.FilePath)
+	if err != nil {
+		klog.V(1).Infof("os.Open failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	f := &File{
+		options:  &cfg,
+		file:     file,
+		dataSize: -1,
+		stopChan: make(chan struct{}),
+		muted:    false,
+	}
+
+	err = f.parseHeader()
+	if err != nil {
+		klog.V(1).Infof("parseHeader failed. Err: %v\n", err)
+		file.Close()
+		return nil, err
+	}
+
+	// looping over nothing would spin forever
+	empty, err := f.isEmpty()
+	if err != nil {
+		klog.V(1).Infof("isEmpty failed. Err: %v\n", err)
+		file.Close()
+		return nil, err
+	}
+	if empty {
+		klog.V(1).Infof("%s has no audio\n", cfg.FilePath)
+		file.Close()
+		return nil, ErrNoAudio
+	}
+
+	klog.V(4).Infof("file.New succeeded. channels: %d, rate: %d\n", f.options.InputChannels, f.options.SamplingRate)
+	return f, nil
+}
+
+// InputChannels returns the number of channels in the file
+func (f *File) InputChannels() int {
+	return f.options.InputChannels
+}
+
+// SamplingRate returns the sampling rate of the file
+func (f *File) SamplingRate() int {
+	return f.options.SamplingRate
+}
+
+// Start is a no-op for files, streaming begins on Stream
+func (f *File) Start() error {
+	klog.V(4).Infof("Start() succeeded\n")
+	return nil
+}
+
+// Stream writes the file contents to a source in chunks the size of a mic buffer
+func (f *File) Stream(w io.Writer) error {
+	_, err := f.file.Seek(f.dataOffset, io.SeekStart)
+	if err != nil {
+		klog.V(1).Infof("file.Seek failed. Err: %v\n", err)
+		return err
+	}
+
+	reader := f.dataReader()
+	chunk := make([]byte, DefaultChunkSize*2)
+	silence := make([]byte, len(chunk))
+
+	bytesPerSecond := int64(f.options.SamplingRate * f.options.InputChannels * 2)
+	start := time.Now()
+	var sent, pass int64
+
+	for {
+		select {
+		case <-f.stopChan:
+			return nil
+		default:
+			n, err := io.ReadFull(reader, chunk)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				if f.isStopped() {
+					return nil
+				}
+				klog.V(1).Infof("file.Read failed. Err: %v\n", err)
+				return err
+			}
+
+			if n > 0 {
+				buf := chunk[:n]
+				if f.isMuted() {
+					klog.V(7).Infof("File is MUTED!\n")
+					buf = silence[:n]
+				}
+
+				byteCount, errWrite := w.Write(buf)
+				if errWrite != nil {
+					klog.V(1).Infof("w.Write failed. Err: %v\n", errWrite)
+					return errWrite
+				}
+				klog.V(7).Infof("io.Writer succeeded. Bytes written: %d\n", byteCount)
+
+				sent += int64(n)
+				pass += int64(n)
+				if f.options.RealTime {
+					ahead := time.Duration(sent*int64(time.Second)/bytesPerSecond) - time.Since(start)
+					if ahead > 0 {
+						select {
+						case <-f.stopChan:
+							return nil
+						case <-time.After(ahead):
+						}
+					}
+				}
+			}
+
+			if err == nil {
+				continue
+			}
+
+			// end of the file
+			if !f.options.Loop {
+				klog.V(4).Infof("End of file reached\n")
+				return nil
+			}
+
+			// the file was truncated after New
+			if pass == 0 {
+				klog.V(1).Infof("Nothing read from the file. Not looping.\n")
+				return ErrNoAudio
+			}
+			pass = 0
+
+			klog.V(5).Infof("End of file reached. Looping...\n")
+			_, err = f.file.Seek(f.dataOffset, io.SeekStart)
+			if err != nil {
+				klog.V(1).Infof("file.Seek failed. Err: %v\n", err)
+				return err
+			}
+			reader = f.dataReader()
+		}
+	}
+}
+
+// Mute silences the file
+func (f *File) Mute() {
+	f.mute.Lock()
+	f.muted = true
+	f.mute.Unlock()
+}
+
+// Unmute restores the audio in the file
+func (f *File) Unmute() {
+	f.mute.Lock()
+	f.muted = false
+	f.mute.Unlock()
+}
+
+// Stop terminates streaming the file
+func (f *File) Stop() error {
+	f.stopOnce.Do(func() {
+		close(f.stopChan)
+	})
+
+	err := f.file.Close()
+	if err != nil {
+		klog.V(1).Infof("file.Close failed. Err: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (f *File) isMuted() bool {
+	f.mute.Lock()
+	defer f.mute.Unlock()
+	return f.muted
+}
+
+func (f *File) isStopped() bool {
+	select {
+	case <-f.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *File) dataReader() io.Reader {
+	if f.dataSize < 0 {
+		return f.file
+	}
+	return io.LimitReader(f.file, f.dataSize)
+}
+
+// isEmpty returns whether there is no audio after the header
+func (f *File) isEmpty() (bool, error) {
+	if f.dataSize >= 0 {
+		return f.dataSize == 0, nil
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() <= f.dataOffset, nil
+}
+
+// parseHeader detects a RIFF/WAVE header and locates the data chunk. Files
+// without a header are treated as raw PCM.
+func (f *File) parseHeader() error {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(f.file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	if n < 12 || !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		klog.V(4).Infof("No WAV header found, treating as raw PCM\n")
+		f.dataOffset = 0
+		return nil
+	}
+
+	offset := int64(12)
+	foundFmt := false
+	chunkHeader := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(f.file, chunkHeader)
+		if err != nil {
+			klog.V(1).Infof("data chunk not found. Err: %v\n", err)
+			return ErrInvalidWav
+		}
+		offset += 8
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return ErrInvalidWav
+			}
+			format := make([]byte, size)
+			_, err := io.ReadFull(f.file, format)
+			if err != nil {
+				return ErrInvalidWav
+			}
+
+			audioFormat := binary.LittleEndian.Uint16(format[0:2])
+			channels := binary.LittleEndian.Uint16(format[2:4])
+			sampleRate := binary.LittleEndian.Uint32(format[4:8])
+			bitsPerSample := binary.LittleEndian.Uint16(format[14:16])
+
+			// 1 = PCM, 0xFFFE = WAVE_FORMAT_EXTENSIBLE
+			if (audioFormat != 1 && audioFormat != 0xFFFE) || bitsPerSample != 16 || channels == 0 {
+				klog.V(1).Infof("format: %d, bits: %d, channels: %d\n", audioFormat, bitsPerSample, channels)
+				return ErrUnsupportedFormat
+			}
+
+			f.options.InputChannels = int(channels)
+			f.options.SamplingRate = int(sampleRate)
+			foundFmt = true
+		case "data":
+			if !foundFmt {
+				return ErrInvalidWav
+			}
+			f.dataOffset = offset
+			f.dataSize = size
+			return nil
+		default:
+			_, err := f.file.Seek(size, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		}
+
+		// chunks are word aligned
+		if size%2 == 1 {
+			_, err := f.file.Seek(1, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			size++
+		}
+		offset += size
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"os"
+	"sync"
+)
+
+// FileConfig init config for a file source
+type FileConfig struct {
+	FilePath string
+
+	// InputChannels and SamplingRate describe raw PCM files. For WAV files
+	// these are read from the header.
+	InputChannels int
+	SamplingRate  int
+
+	// RealTime paces the stream at the rate it was recorded
+	RealTime bool
+	// Loop restarts from the beginning when the end of the file is reached
+	Loop bool
+}
+
+// File streams a WAV or raw little endian int16 PCM file
+type File struct {
+	options *FileConfig
+
+	// file
+	file       *os.File
+	dataOffset int64
+	dataSize   int64
+
+	// operational
+	stopChan chan struct{}
+	stopOnce sync.Once
+	mute     sync.Mutex
+	muted    bool
+}
+
+// PCM a whole file decoded into little endian int16 samples
+type PCM struct {
+	Data          []byte
+	InputChannels int
+	SamplingRate  int
+}
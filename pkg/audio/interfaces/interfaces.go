@@ -0,0 +1,31 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+import (
+	"io"
+	"time"
+)
+
+// AudioSource produces raw linear16 (little endian int16) audio for a transcriber
+type AudioSource interface {
+	Start() error
+	Stop() error
+	Stream(w io.Writer) error
+	Mute()
+	Unmute()
+}
+
+// VoiceActivityCallback is notified when the voice activity detector sees speech start or end
+type VoiceActivityCallback interface {
+	SpeechStart() error
+	SpeechEnd() error
+}
+
+// DeviceStatusCallback is notified when the audio device fails and when it comes back
+type DeviceStatusCallback interface {
+	DeviceLost(err error) error
+	DeviceRecovered(downtime time.Duration) error
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	wavHeaderSize int = 44
+)
+
+// New creates a new session recorder
+func New(cfg RecorderConfig) (*Recorder, error) {
+	if cfg.InputChannels == 0 {
+		cfg.InputChannels = 1
+	}
+	if cfg.SamplingRate == 0 {
+		cfg.SamplingRate = 16000
+	}
+	if cfg.Directory == "" {
+		cfg.Directory = "."
+	}
+	if cfg.SessionID == "" {
+		cfg.SessionID = time.Now().Format("20060102-150405")
+	}
+
+	err := os.MkdirAll(cfg.Directory, 0755)
+	if err != nil {
+		klog.V(1).Infof("os.MkdirAll failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	r := &Recorder{
+		options: &cfg,
+	}
+
+	klog.V(4).Infof("recorder.New succeeded. Session: %s\n", cfg.SessionID)
+	return r, nil
+}
+
+// SessionID returns the ID used to name this session's recordings
+func (r *Recorder) SessionID() string {
+	return r.options.SessionID
+}
+
+// Write appends PCM to the current utterance, starting a new one if needed.
+// Failures are logged and never returned so recording cannot interrupt transcription.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		err := r.open()
+		if err != nil {
+			klog.V(1).Infof("recorder.open failed. Err: %v\n", err)
+			return len(p), nil
+		}
+	}
+
+	n, err := r.file.Write(p)
+	if err != nil {
+		klog.V(1).Infof("file.Write failed. Err: %v\n", err)
+	}
+	r.dataSize += uint32(n)
+
+	return len(p), nil
+}
+
+// Segment ends the current utterance and saves the transcript next to its audio
+func (r *Recorder) Segment(transcript string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		klog.V(4).Infof("No audio recorded for transcript. Skipping...\n")
+		return nil
+	}
+
+	name := r.file.Name()
+	err := r.close()
+	if err != nil {
+		klog.V(1).Infof("recorder.close failed. Err: %v\n", err)
+		return err
+	}
+
+	txtName := name[:len(name)-len(filepath.Ext(name))] + ".txt"
+	err = os.WriteFile(txtName, []byte(transcript+"\n"), 0644)
+	if err != nil {
+		klog.V(1).Infof("os.WriteFile failed. Err: %v\n", err)
+		return err
+	}
+
+	klog.V(4).Infof("Recorded utterance: %s\n", name)
+	return nil
+}
+
+// Close finishes the current recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.close()
+}
+
+func (r *Recorder) open() error {
+	r.segmentID++
+	r.started = time.Now()
+	r.dataSize = 0
+
+	name := fmt.Sprintf("%s_%04d_%s.wav", r.options.SessionID, r.segmentID, r.started.Format("20060102T150405.000"))
+	file, err := os.Create(filepath.Join(r.options.Directory, name))
+	if err != nil {
+		return err
+	}
+
+	// sizes are filled in on close
+	_, err = file.Write(r.header())
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	return nil
+}
+
+func (r *Recorder) close() error {
+	defer func() {
+		r.file = nil
+	}()
+
+	_, err := r.file.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = r.file.Write(r.header())
+	}
+	if err != nil {
+		klog.V(1).Infof("Updating wav header failed. Err: %v\n", err)
+		r.file.Close()
+		return err
+	}
+
+	return r.file.Close()
+}
+
+func (r *Recorder) header() []byte {
+	channels := uint16(r.options.InputChannels)
+	rate := uint32(r.options.SamplingRate)
+	blockAlign := channels * 2
+
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:8], uint32(wavHeaderSize-8)+r.dataSize)
+	copy(h[8:12], "WAVE")
+	copy(h[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:20], 16)
+	binary.LittleEndian.PutUint16(h[20:22], 1)
+	binary.LittleEndian.PutUint16(h[22:24], channels)
+	binary.LittleEndian.PutUint32(h[24:28], rate)
+	binary.LittleEndian.PutUint32(h[28:32], rate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(h[32:34], blockAlign)
+	binary.LittleEndian.PutUint16(h[34:36], 16)
+	copy(h[36:40], "data")
+	binary.LittleEndian.PutUint32(h[40:44], r.dataSize)
+
+	return h
+}
@@ -0,0 +1,35 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// RecorderConfig init config for the session recorder
+type RecorderConfig struct {
+	// Directory where recordings are written, created if missing
+	Directory string
+	// SessionID prefixes every file. Defaults to the session start time.
+	SessionID string
+
+	InputChannels int
+	SamplingRate  int
+}
+
+// Recorder writes the audio sent to a transcriber as one WAV file per utterance
+type Recorder struct {
+	options *RecorderConfig
+
+	// current segment
+	file      *os.File
+	dataSize  uint32
+	started   time.Time
+	segmentID int
+
+	mu sync.Mutex
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package splitter
+
+import (
+	"io"
+
+	klog "k8s.io/klog/v2"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+)
+
+// New creates a splitter for a source producing the given number of interleaved channels
+func New(source interfaces.AudioSource, channels int) *Splitter {
+	s := &Splitter{
+		source: source,
+	}
+
+	for i := 0; i < channels; i++ {
+		s.channels = append(s.channels, &Channel{
+			splitter: s,
+			index:    i,
+			stopChan: make(chan struct{}),
+			errChan:  make(chan error, 1),
+		})
+	}
+
+	return s
+}
+
+// Channel returns the mono source for channel index
+func (s *Splitter) Channel(index int) *Channel {
+	return s.channels[index]
+}
+
+// Channels returns the number of channels
+func (s *Splitter) Channels() int {
+	return len(s.channels)
+}
+
+// Write deinterleaves the audio and passes each channel to its writer
+func (s *Splitter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frameSize := len(s.channels) * 2
+
+	data := p
+	if len(s.remainder) > 0 {
+		data = append(s.remainder, p...)
+	}
+	frames := len(data) / frameSize
+
+	for _, channel := range s.channels {
+		if channel.writer == nil {
+			continue
+		}
+
+		if cap(channel.buf) < frames*2 {
+			channel.buf = make([]byte, frames*2)
+		}
+		channel.buf = channel.buf[:frames*2]
+
+		if channel.isMuted() {
+			for i := range channel.buf {
+				channel.buf[i] = 0
+			}
+		} else {
+			offset := channel.index * 2
+			for f := 0; f < frames; f++ {
+				copy(channel.buf[f*2:f*2+2], data[f*frameSize+offset:])
+			}
+		}
+
+		// the other channels keep going, this one returns the error from Stream
+		_, err := channel.writer.Write(channel.buf)
+		if err != nil {
+			klog.V(1).Infof("Channel %d w.Write failed. Err: %v\n", channel.index, err)
+			channel.writer = nil
+			channel.errChan <- err
+		}
+	}
+
+	s.remainder = append(s.remainder[:0], data[frames*frameSize:]...)
+
+	return len(p), nil
+}
+
+// start starts the underlying source with the first channel
+func (s *Splitter) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started++
+	if s.started > 1 {
+		return nil
+	}
+
+	err := s.source.Start()
+	if err != nil {
+		klog.V(1).Infof("source.Start failed. Err: %v\n", err)
+		s.started--
+		return err
+	}
+
+	// this is a blocking call
+	go func() {
+		err := s.source.Stream(s)
+		if err != nil {
+			klog.V(1).Infof("source.Stream failed. Err: %v\n", err)
+		}
+	}()
+
+	klog.V(4).Infof("Splitter started with %d channels\n", len(s.channels))
+	return nil
+}
+
+// stop stops the underlying source with the last channel
+func (s *Splitter) stop() error {
+	s.mu.Lock()
+	s.stopped++
+	last := s.stopped == s.started
+	s.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+
+	err := s.source.Stop()
+	if err != nil {
+		klog.V(1).Infof("source.Stop failed. Err: %v\n", err)
+		return err
+	}
+
+	klog.V(4).Infof("Splitter stopped\n")
+	return nil
+}
+
+// Start begins the underlying source if it is not already running
+func (c *Channel) Start() error {
+	return c.splitter.start()
+}
+
+// Stream sends this channel's audio to w until stopped or a write to w fails
+func (c *Channel) Stream(w io.Writer) error {
+	c.splitter.mu.Lock()
+	c.writer = w
+	c.splitter.mu.Unlock()
+
+	select {
+	case <-c.stopChan:
+		return nil
+	case err := <-c.errChan:
+		return err
+	}
+}
+
+// Mute silences this channel only
+func (c *Channel) Mute() {
+	c.mute.Lock()
+	c.muted = true
+	c.mute.Unlock()
+}
+
+// Unmute restores this channel
+func (c *Channel) Unmute() {
+	c.mute.Lock()
+	c.muted = false
+	c.mute.Unlock()
+}
+
+// Stop terminates this channel, stopping the underlying source with the last one
+func (c *Channel) Stop() error {
+	var err error
+	c.stopOnce.Do(func() {
+		c.splitter.mu.Lock()
+		c.writer = nil
+		c.splitter.mu.Unlock()
+
+		close(c.stopChan)
+		err = c.splitter.stop()
+	})
+	return err
+}
+
+func (c *Channel) isMuted() bool {
+	c.mute.Lock()
+	defer c.mute.Unlock()
+	return c.muted
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package splitter
+
+import (
+	"io"
+	"sync"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+)
+
+// Splitter turns one interleaved multi-channel source into one mono source per channel
+type Splitter struct {
+	source   interfaces.AudioSource
+	channels []*Channel
+
+	// partial frame left over from the previous write
+	remainder []byte
+
+	// operational
+	mu      sync.Mutex
+	started int
+	stopped int
+}
+
+// Channel is a mono AudioSource for a single channel of a Splitter
+type Channel struct {
+	splitter *Splitter
+	index    int
+
+	writer io.Writer
+	buf    []byte
+
+	// operational
+	stopChan chan struct{}
+	errChan  chan error
+	stopOnce sync.Once
+	mute     sync.Mutex
+	muted    bool
+}
@@ -0,0 +1,35 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package vad
+
+import (
+	"time"
+)
+
+const (
+	// DefaultThresholdRatio energy must be this many times the noise floor to count as speech
+	DefaultThresholdRatio float64 = 3.0
+
+	// DefaultMinEnergy RMS below this is never considered speech
+	DefaultMinEnergy float64 = 200.0
+
+	// DefaultMaxZeroCrossingRate frames crossing zero more often than this are treated as noise
+	DefaultMaxZeroCrossingRate float64 = 0.35
+
+	// DefaultHangover how long speech must be absent before speech end is signaled
+	DefaultHangover time.Duration = 800 * time.Millisecond
+
+	// DefaultNoiseAdaptRate how quickly the noise floor follows non-speech frames
+	DefaultNoiseAdaptRate float64 = 0.05
+
+	// DefaultSpeechAdaptRate how quickly the noise floor rises during speech, slow enough
+	// to ride out a long sentence but so that louder background noise, like a fan
+	// turning on, stops counting as speech after about twenty seconds
+	DefaultSpeechAdaptRate float64 = 0.002
+
+	// DefaultCalibration how much audio is averaged to seed the noise floor. Nothing
+	// counts as speech until it has been heard.
+	DefaultCalibration time.Duration = 500 * time.Millisecond
+)
@@ -0,0 +1,49 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package vad
+
+import (
+	"sync"
+	"time"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+)
+
+// VADConfig init config for the voice activity detector
+type VADConfig struct {
+	InputChannels int
+	SamplingRate  int
+
+	ThresholdRatio      float64
+	MinEnergy           float64
+	MaxZeroCrossingRate float64
+	Hangover            time.Duration
+	NoiseAdaptRate      float64
+	SpeechAdaptRate     float64
+	Calibration         time.Duration
+
+	// Gate only passes audio to the transcriber while speech is active. Streaming
+	// transcribers keep their connection open through the silence themselves.
+	Gate bool
+
+	Callback *interfaces.VoiceActivityCallback
+}
+
+// Detector is an energy and zero-crossing based voice activity detector
+type Detector struct {
+	options *VADConfig
+
+	noiseFloor  float64
+	initialized bool
+	silentFor   time.Duration
+
+	// noise floor calibration
+	calibratedFor  time.Duration
+	calibrationSum float64
+	calibrationN   int
+
+	mu     sync.Mutex
+	active bool
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package vad
+
+import (
+	"math"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// New creates a new voice activity detector
+func New(cfg VADConfig) *Detector {
+	if cfg.InputChannels == 0 {
+		cfg.InputChannels = 1
+	}
+	if cfg.SamplingRate == 0 {
+		cfg.SamplingRate = 16000
+	}
+	if cfg.ThresholdRatio == 0 {
+		cfg.ThresholdRatio = DefaultThresholdRatio
+	}
+	if cfg.MinEnergy == 0 {
+		cfg.MinEnergy = DefaultMinEnergy
+	}
+	if cfg.MaxZeroCrossingRate == 0 {
+		cfg.MaxZeroCrossingRate = DefaultMaxZeroCrossingRate
+	}
+	if cfg.Hangover == 0 {
+		cfg.Hangover = DefaultHangover
+	}
+	if cfg.NoiseAdaptRate == 0 {
+		cfg.NoiseAdaptRate = DefaultNoiseAdaptRate
+	}
+	if cfg.SpeechAdaptRate == 0 {
+		cfg.SpeechAdaptRate = DefaultSpeechAdaptRate
+	}
+	if cfg.Calibration == 0 {
+		cfg.Calibration = DefaultCalibration
+	}
+
+	return &Detector{
+		options: &cfg,
+	}
+}
+
+// Process analyzes a buffer of interleaved samples and returns whether speech is active.
+// SpeechStart and SpeechEnd are signaled on the callback when the state changes.
+func (d *Detector) Process(samples []int16) bool {
+	if len(samples) == 0 {
+		return d.Active()
+	}
+
+	rms, zcr := d.measure(samples)
+	frameDuration := time.Duration(len(samples)/d.options.InputChannels) * time.Second / time.Duration(d.options.SamplingRate)
+
+	// seed the noise floor with the average over the calibration window so a single
+	// click or word at startup does not throw it off
+	if !d.initialized {
+		d.calibratedFor += frameDuration
+		d.calibrationSum += rms
+		d.calibrationN++
+		if d.calibratedFor < d.options.Calibration {
+			klog.V(7).Infof("vad calibrating rms: %f\n", rms)
+			return d.Active()
+		}
+
+		d.noiseFloor = math.Max(d.calibrationSum/float64(d.calibrationN), 1)
+		d.initialized = true
+		klog.V(5).Infof("vad noise floor calibrated: %f\n", d.noiseFloor)
+	}
+
+	threshold := math.Max(d.noiseFloor*d.options.ThresholdRatio, d.options.MinEnergy)
+	isSpeech := rms >= threshold && zcr <= d.options.MaxZeroCrossingRate
+	klog.V(7).Infof("vad rms: %f, zcr: %f, floor: %f, speech: %t\n", rms, zcr, d.noiseFloor, isSpeech)
+
+	// the floor only creeps up during speech, otherwise a step in background noise
+	// would be speech forever
+	rate := d.options.NoiseAdaptRate
+	if isSpeech {
+		rate = d.options.SpeechAdaptRate
+	}
+	d.noiseFloor += (rms - d.noiseFloor) * rate
+	d.noiseFloor = math.Max(d.noiseFloor, 1)
+
+	d.mu.Lock()
+	wasActive := d.active
+	if isSpeech {
+		d.silentFor = 0
+		d.active = true
+	} else if d.active {
+		d.silentFor += frameDuration
+		if d.silentFor >= d.options.Hangover {
+			d.active = false
+		}
+	}
+	active := d.active
+	d.mu.Unlock()
+
+	if d.options.Callback != nil {
+		if active && !wasActive {
+			klog.V(4).Infof("VAD speech start\n")
+			err := (*d.options.Callback).SpeechStart()
+			if err != nil {
+				klog.V(1).Infof("SpeechStart failed. Err: %v\n", err)
+			}
+		} else if !active && wasActive {
+			klog.V(4).Infof("VAD speech end\n")
+			err := (*d.options.Callback).SpeechEnd()
+			if err != nil {
+				klog.V(1).Infof("SpeechEnd failed. Err: %v\n", err)
+			}
+		}
+	}
+
+	return active
+}
+
+// Active returns whether speech is currently detected
+func (d *Detector) Active() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+// Gate returns whether audio should only be sent while speech is active
+func (d *Detector) Gate() bool {
+	return d.options.Gate
+}
+
+// Reset forgets the current speech state and noise floor
+func (d *Detector) Reset() {
+	d.mu.Lock()
+	d.active = false
+	d.mu.Unlock()
+
+	d.silentFor = 0
+	d.initialized = false
+	d.noiseFloor = 0
+	d.calibratedFor = 0
+	d.calibrationSum = 0
+	d.calibrationN = 0
+}
+
+func (d *Detector) measure(samples []int16) (float64, float64) {
+	var sum float64
+	crossings := 0
+	for i, s := range samples {
+		sum += float64(s) * float64(s)
+
+		// only compare samples within the same channel
+		if i >= d.options.InputChannels {
+			prev := samples[i-d.options.InputChannels]
+			if (prev >= 0) != (s >= 0) {
+				crossings++
+			}
+		}
+	}
+
+	rms := math.Sqrt(sum / float64(len(samples)))
+	zcr := 0.0
+	if len(samples) > d.options.InputChannels {
+		zcr = float64(crossings) / float64(len(samples)-d.options.InputChannels)
+	}
+	return rms, zcr
+}
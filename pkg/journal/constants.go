@@ -0,0 +1,17 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+const (
+	// DefaultMaxSize the journal is rotated once it would grow past this many bytes
+	DefaultMaxSize int64 = 10 * 1024 * 1024
+
+	// DefaultMaxFiles rotated journals kept, oldest are deleted first
+	DefaultMaxFiles int = 10
+
+	// FileName the journal currently being written to. Rotated journals are renamed
+	// journal-<time>.jsonl.
+	FileName string = "journal.jsonl"
+)
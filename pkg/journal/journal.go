@@ -0,0 +1,172 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// New opens the journal, appending to one left by an earlier session
+func New(cfg JournalConfig) (*Journal, error) {
+	if cfg.Directory == "" {
+		cfg.Directory = "."
+	}
+	if cfg.SessionID == "" {
+		cfg.SessionID = time.Now().Format("20060102-150405")
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+
+	err := os.MkdirAll(cfg.Directory, 0755)
+	if err != nil {
+		klog.V(1).Infof("os.MkdirAll failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	j := &Journal{
+		options: &cfg,
+	}
+
+	err = j.open()
+	if err != nil {
+		klog.V(1).Infof("journal.open failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	klog.V(4).Infof("journal.New succeeded. Session: %s\n", cfg.SessionID)
+	return j, nil
+}
+
+// SessionID returns the ID written on every entry
+func (j *Journal) SessionID() string {
+	return j.options.SessionID
+}
+
+// Write appends an entry, rotating the journal first if it is full
+func (j *Journal) Write(entry *Entry) error {
+	if entry.SessionID == "" {
+		entry.SessionID = j.options.SessionID
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		klog.V(1).Infof("json.Marshal failed. Err: %v\n", err)
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		err := j.open()
+		if err != nil {
+			klog.V(1).Infof("journal.open failed. Err: %v\n", err)
+			return err
+		}
+	}
+
+	if j.size > 0 && j.size+int64(len(line)) > j.options.MaxSize {
+		err := j.rotate()
+		if err != nil {
+			klog.V(1).Infof("journal.rotate failed. Err: %v\n", err)
+			return err
+		}
+	}
+
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		klog.V(1).Infof("file.Write failed. Err: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the journal
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+func (j *Journal) open() error {
+	file, err := os.OpenFile(filepath.Join(j.options.Directory, FileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	j.file = file
+	j.size = info.Size()
+	return nil
+}
+
+// rotate renames the current journal out of the way, starts a new one and deletes
+// the oldest rotated journals beyond MaxFiles
+func (j *Journal) rotate() error {
+	err := j.file.Close()
+	j.file = nil
+	if err != nil {
+		klog.V(1).Infof("file.Close failed. Err: %v\n", err)
+	}
+
+	ext := filepath.Ext(FileName)
+	base := FileName[:len(FileName)-len(ext)]
+	rotated := filepath.Join(j.options.Directory, base+"-"+time.Now().Format("20060102T150405.000")+ext)
+
+	err = os.Rename(filepath.Join(j.options.Directory, FileName), rotated)
+	if err != nil {
+		return err
+	}
+	klog.V(3).Infof("Journal rotated to %s\n", rotated)
+
+	err = j.open()
+	if err != nil {
+		return err
+	}
+
+	// the timestamps in the names sort oldest first
+	old, err := filepath.Glob(filepath.Join(j.options.Directory, base+"-*"+ext))
+	if err != nil {
+		klog.V(1).Infof("filepath.Glob failed. Err: %v\n", err)
+		return nil
+	}
+	sort.Strings(old)
+	for len(old) > j.options.MaxFiles {
+		err := os.Remove(old[0])
+		if err != nil {
+			klog.V(1).Infof("os.Remove failed. Err: %v\n", err)
+		}
+		old = old[1:]
+	}
+
+	return nil
+}
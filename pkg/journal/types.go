@@ -0,0 +1,54 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// JournalConfig init config for the transcript journal
+type JournalConfig struct {
+	// Directory where the journal is written, created if missing
+	Directory string
+	// SessionID is written on every entry. Defaults to the session start time.
+	SessionID string
+
+	// MaxSize bytes before the journal is rotated
+	MaxSize int64
+	// MaxFiles rotated journals to keep
+	MaxFiles int
+}
+
+// Entry one final transcript and what the assistant did with it
+type Entry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	SessionID   string    `json:"session_id"`
+	UtteranceID string    `json:"utterance_id,omitempty"`
+	Backend     string    `json:"backend,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Channel     int       `json:"channel"`
+
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	LatencyMs  int64   `json:"latency_ms,omitempty"`
+
+	// Triggered the assistant responded to the transcript, Reply is what it said
+	Triggered bool   `json:"triggered"`
+	Reply     string `json:"reply,omitempty"`
+
+	// Error returned by the assistant while handling the transcript
+	Error string `json:"error,omitempty"`
+}
+
+// Journal appends entries to a rotating JSON Lines file
+type Journal struct {
+	options *JournalConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
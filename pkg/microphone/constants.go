@@ -0,0 +1,48 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// FramesPerBuffer frames read from the device at a time, each frame holds one sample per channel
+	FramesPerBuffer int = 2048
+
+	// DefaultQueueSize frames buffered between capture and the writer, about 4 seconds at 16kHz
+	DefaultQueueSize int = 32
+
+	// DefaultRecoveryBackoff initial wait before reopening a failed device
+	DefaultRecoveryBackoff time.Duration = 500 * time.Millisecond
+	// DefaultMaxRecoveryBackoff longest wait between attempts to reopen a failed device
+	DefaultMaxRecoveryBackoff time.Duration = 10 * time.Second
+)
+
+const (
+	// ClippingLevel absolute sample value counted as clipped
+	ClippingLevel float64 = 32000
+	// ClippingRatioThreshold fraction of clipped samples that flags a frame as clipping
+	ClippingRatioThreshold float64 = 0.01
+
+	// DeadMicLevel peak at or below this is treated as no signal at all
+	DeadMicLevel float64 = 2
+	// DeadMicDuration how long without signal before the mic is reported dead
+	DeadMicDuration time.Duration = 3 * time.Second
+
+	// NoiseFloorRiseRate how quickly the noise floor estimate rises toward louder frames
+	NoiseFloorRiseRate float64 = 0.01
+)
+
+var (
+	// StandardSampleRates sample rates probed when listing input devices
+	StandardSampleRates = []float64{8000, 11025, 16000, 22050, 32000, 44100, 48000}
+)
+
+var (
+	// ErrDeviceNotFound no input device matched the requested name or index
+	ErrDeviceNotFound = errors.New("input device not found")
+)
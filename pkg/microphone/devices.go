@@ -0,0 +1,138 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// ListInputDevices returns all devices capable of recording audio
+func ListInputDevices() ([]InputDevice, error) {
+	err := initialize()
+	if err != nil {
+		klog.V(1).Infof("portaudio.Initialize failed. Err: %v\n", err)
+		return nil, err
+	}
+	defer terminate()
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		klog.V(1).Infof("portaudio.Devices failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	defaultName := ""
+	if defaultDevice, err := portaudio.DefaultInputDevice(); err == nil {
+		defaultName = defaultDevice.Name
+	}
+
+	inputs := make([]InputDevice, 0)
+	for index, device := range devices {
+		if device.MaxInputChannels == 0 {
+			continue
+		}
+
+		input := InputDevice{
+			Index:             index,
+			Name:              device.Name,
+			MaxInputChannels:  device.MaxInputChannels,
+			DefaultSampleRate: device.DefaultSampleRate,
+			IsDefault:         device.Name == defaultName,
+		}
+		if device.HostApi != nil {
+			input.HostApi = device.HostApi.Name
+		}
+
+		buf := make([]int16, FramesPerBuffer)
+		for _, rate := range StandardSampleRates {
+			params := inputParameters(device, 1, rate, FramesPerBuffer)
+			if portaudio.IsFormatSupported(params, buf) == nil {
+				input.SupportedSampleRates = append(input.SupportedSampleRates, rate)
+			}
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// PrintDevices writes the input devices and the rates they support to w
+func PrintDevices(w io.Writer) error {
+	devices, err := ListInputDevices()
+	if err != nil {
+		klog.V(1).Infof("ListInputDevices failed. Err: %v\n", err)
+		return err
+	}
+
+	fmt.Fprintf(w, "\nAvailable input devices:\n\n")
+	for _, device := range devices {
+		isDefault := ""
+		if device.IsDefault {
+			isDefault = " (default)"
+		}
+
+		rates := make([]string, 0)
+		for _, rate := range device.SupportedSampleRates {
+			rates = append(rates, fmt.Sprintf("%.0f", rate))
+		}
+
+		fmt.Fprintf(w, "[%d] %s%s\n", device.Index, device.Name, isDefault)
+		fmt.Fprintf(w, "    Host API: %s, Max Input Channels: %d, Default Rate: %.0f\n", device.HostApi, device.MaxInputChannels, device.DefaultSampleRate)
+		fmt.Fprintf(w, "    Supported Rates (mono): %s\n\n", strings.Join(rates, ", "))
+	}
+
+	fmt.Fprintf(w, "Select a device by setting ASSISTANT_INPUT_DEVICE to its index or part of its name.\n\n")
+	return nil
+}
+
+// findInputDevice looks up a device by index or case-insensitive name substring
+func findInputDevice(selector string) (*portaudio.DeviceInfo, error) {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		klog.V(1).Infof("portaudio.Devices failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 0 || index >= len(devices) || devices[index].MaxInputChannels == 0 {
+			klog.V(1).Infof("Input device index %d not found\n", index)
+			return nil, ErrDeviceNotFound
+		}
+		return devices[index], nil
+	}
+
+	selector = strings.ToLower(selector)
+	for _, device := range devices {
+		if device.MaxInputChannels == 0 {
+			continue
+		}
+		if strings.Contains(strings.ToLower(device.Name), selector) {
+			return device, nil
+		}
+	}
+
+	klog.V(1).Infof("Input device %s not found\n", selector)
+	return nil, ErrDeviceNotFound
+}
+
+func inputParameters(device *portaudio.DeviceInfo, channels int, rate float64, framesPerBuffer int) portaudio.StreamParameters {
+	return portaudio.StreamParameters{
+		Input: portaudio.StreamDeviceParameters{
+			Device:   device,
+			Channels: channels,
+			Latency:  device.DefaultLowInputLatency,
+		},
+		SampleRate:      rate,
+		FramesPerBuffer: framesPerBuffer,
+	}
+}
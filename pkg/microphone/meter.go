@@ -0,0 +1,109 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"math"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// Subscribe returns a channel receiving a LevelEvent for every frame read from the device
+// and a function to unsubscribe. Events are dropped if the channel is full.
+func (m *Microphone) Subscribe(buffer int) (<-chan LevelEvent, func()) {
+	m.meter.mu.Lock()
+	defer m.meter.mu.Unlock()
+
+	if m.meter.subscribers == nil {
+		m.meter.subscribers = make(map[int]chan LevelEvent)
+	}
+
+	id := m.meter.nextID
+	m.meter.nextID++
+
+	events := make(chan LevelEvent, buffer)
+	m.meter.subscribers[id] = events
+
+	unsubscribe := func() {
+		m.meter.mu.Lock()
+		defer m.meter.mu.Unlock()
+
+		if _, ok := m.meter.subscribers[id]; ok {
+			delete(m.meter.subscribers, id)
+			close(events)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// measure computes levels for a frame and publishes them to subscribers
+func (m *Microphone) measure(samples []int16) {
+	m.meter.mu.Lock()
+	defer m.meter.mu.Unlock()
+
+	if len(m.meter.subscribers) == 0 || len(samples) == 0 {
+		return
+	}
+
+	var sum float64
+	var peak float64
+	clipped := 0
+	for _, s := range samples {
+		v := math.Abs(float64(s))
+		sum += v * v
+		if v > peak {
+			peak = v
+		}
+		if v >= ClippingLevel {
+			clipped++
+		}
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+
+	// noise floor falls quickly and rises slowly so it tracks the quiet between words
+	if m.meter.noiseFloor == 0 || rms < m.meter.noiseFloor {
+		m.meter.noiseFloor = math.Max(rms, 1)
+	} else {
+		m.meter.noiseFloor += (rms - m.meter.noiseFloor) * NoiseFloorRiseRate
+	}
+
+	frameDuration := time.Duration(len(samples)/m.options.InputChannels) * time.Second / time.Duration(m.options.SamplingRate)
+	if peak <= DeadMicLevel {
+		m.meter.silentFor += frameDuration
+	} else {
+		m.meter.silentFor = 0
+	}
+
+	clippingRatio := float64(clipped) / float64(len(samples))
+	event := LevelEvent{
+		Timestamp:      time.Now(),
+		RMS:            rms,
+		RMSDBFS:        toDBFS(rms),
+		Peak:           peak,
+		PeakDBFS:       toDBFS(peak),
+		ClippingRatio:  clippingRatio,
+		NoiseFloor:     m.meter.noiseFloor,
+		NoiseFloorDBFS: toDBFS(m.meter.noiseFloor),
+		Clipping:       clippingRatio >= ClippingRatioThreshold,
+		DeadMic:        m.meter.silentFor >= DeadMicDuration,
+	}
+
+	for id, events := range m.meter.subscribers {
+		select {
+		case events <- event:
+		default:
+			klog.V(7).Infof("Level subscriber %d is full. Dropping event.\n", id)
+		}
+	}
+}
+
+func toDBFS(level float64) float64 {
+	if level <= 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(level/math.MaxInt16)
+}
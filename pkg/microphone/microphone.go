@@ -5,51 +5,175 @@
 package microphone
 
 import (
-	"bytes"
-	"encoding/binary"
 	"io"
+	"sync"
+	"sync/atomic"
 
 	klog "k8s.io/klog/v2"
 
 	"github.com/gordonklaus/portaudio"
+
+	vad "github.com/dvonthenen/open-virtual-assistant/pkg/audio/vad"
+)
+
+// PortAudio counts initializations and only really terminates, and rescans devices
+// on the next initialize, once every one of them has been matched by a terminate
+var (
+	initLock  sync.Mutex
+	initCount int
 )
 
 // Initialize inits the library
 func Initialize() {
-	portaudio.Initialize()
+	initialize()
 }
 
 // Teardown cleans up the library
 func Teardown() {
-	portaudio.Terminate()
+	terminate()
+}
+
+func initialize() error {
+	initLock.Lock()
+	defer initLock.Unlock()
+
+	err := portaudio.Initialize()
+	if err != nil {
+		return err
+	}
+	initCount++
+	return nil
+}
+
+func terminate() error {
+	initLock.Lock()
+	defer initLock.Unlock()
+
+	if initCount == 0 {
+		return nil
+	}
+	initCount--
+	return portaudio.Terminate()
+}
+
+// reinitialize fully terminates the library so devices plugged in since are found,
+// then initializes it as many times as before. Any other open stream is closed by this.
+func reinitialize() error {
+	initLock.Lock()
+	defer initLock.Unlock()
+
+	count := initCount
+	for initCount > 0 {
+		portaudio.Terminate()
+		initCount--
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	for initCount < count {
+		err := portaudio.Initialize()
+		if err != nil {
+			klog.V(1).Infof("portaudio.Initialize failed. Err: %v\n", err)
+			return err
+		}
+		initCount++
+	}
+	return nil
 }
 
 // New creates a new microphone using portaudio
 func New(cfg AudioConfig) (*Microphone, error) {
+	if cfg.InputChannels == 0 {
+		cfg.InputChannels = 1
+	}
+	if cfg.RecoveryBackoff == 0 {
+		cfg.RecoveryBackoff = DefaultRecoveryBackoff
+	}
+	if cfg.MaxRecoveryBackoff == 0 {
+		cfg.MaxRecoveryBackoff = DefaultMaxRecoveryBackoff
+	}
 
 	m := &Microphone{
-		stopChan: make(chan struct{}),
-		intBuf:   make([]int16, 2048),
-		muted:    false,
+		options:   &cfg,
+		stopChan:  make(chan struct{}),
+		intBuf:    make([]int16, FramesPerBuffer*cfg.InputChannels),
+		muted:     false,
+		listening: true,
+		queueSize: cfg.QueueSize,
+		policy:    cfg.DropPolicy,
+		counters:  &counters{},
+	}
+	if m.queueSize <= 0 {
+		m.queueSize = DefaultQueueSize
+	}
+	m.pool.New = func() interface{} {
+		buf := make([]byte, len(m.intBuf)*2)
+		return &buf
 	}
 
-	portaudio.Initialize()
+	if cfg.PreRoll > 0 {
+		m.preRoll = newPreRoll(cfg.PreRoll, cfg.InputChannels, cfg.SamplingRate)
+	}
 
-	stream, err := portaudio.OpenDefaultStream(cfg.InputChannels, 0, float64(cfg.SamplingRate), len(m.intBuf), m.intBuf)
+	if cfg.VoiceActivity != nil {
+		vadCfg := *cfg.VoiceActivity
+		vadCfg.InputChannels = cfg.InputChannels
+		vadCfg.SamplingRate = int(cfg.SamplingRate)
+		m.detector = vad.New(vadCfg)
+	}
+
+	initialize()
+
+	stream, err := m.openStream()
 	if err != nil {
-		klog.V(1).Infof("OpenDefaultStream failed. Err: %v\n", err)
+		klog.V(1).Infof("openStream failed. Err: %v\n", err)
 		return nil, err
 	}
 
 	// housekeeping
 	m.stream = stream
 
-	klog.V(4).Infof("OpenDefaultStream succeeded\n")
 	return m, nil
 }
 
+// openStream opens the configured device, or the default device when none is selected
+func (m *Microphone) openStream() (*portaudio.Stream, error) {
+	cfg := m.options
+
+	if cfg.InputDevice == "" {
+		stream, err := portaudio.OpenDefaultStream(cfg.InputChannels, 0, float64(cfg.SamplingRate), FramesPerBuffer, m.intBuf)
+		if err != nil {
+			klog.V(1).Infof("OpenDefaultStream failed. Err: %v\n", err)
+			return nil, err
+		}
+
+		klog.V(4).Infof("OpenDefaultStream succeeded\n")
+		return stream, nil
+	}
+
+	device, err := findInputDevice(cfg.InputDevice)
+	if err != nil {
+		klog.V(1).Infof("findInputDevice failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	params := inputParameters(device, cfg.InputChannels, float64(cfg.SamplingRate), FramesPerBuffer)
+	stream, err := portaudio.OpenStream(params, m.intBuf)
+	if err != nil {
+		klog.V(1).Infof("OpenStream failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	klog.V(4).Infof("OpenStream succeeded. Device: %s\n", device.Name)
+	return stream, nil
+}
+
 // Start begins the listening on the microphone
 func (m *Microphone) Start() error {
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
+
 	err := m.stream.Start()
 	if err != nil {
 		klog.V(1).Infof("Mic failed to start. Err: %v\n", err)
@@ -60,44 +184,79 @@ func (m *Microphone) Start() error {
 	return nil
 }
 
-// Read gets the raw bits generated by the mic
+// Read gets the raw bits generated by the mic. The samples are copied into a new
+// slice the caller keeps, use ReadInto to reuse a buffer instead.
 func (m *Microphone) Read() ([]int16, error) {
+	buf := make([]int16, len(m.intBuf))
+	_, err := m.ReadInto(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ReadInto reads one buffer from the mic into dst, which must hold at least
+// FramesPerBuffer samples per channel. Returns the number of samples copied.
+func (m *Microphone) ReadInto(dst []int16) (int, error) {
+	if len(dst) < len(m.intBuf) {
+		klog.V(1).Infof("ReadInto needs %d samples, got %d\n", len(m.intBuf), len(dst))
+		return 0, io.ErrShortBuffer
+	}
+
 	err := m.stream.Read()
 	if err != nil {
 		klog.V(1).Infof("stream.Read failed. Err: %v\n", err)
-		return nil, err
+		return 0, err
 	}
 
-	buf := make([]int16, 2048)
-	_ = copy(buf, m.intBuf)
-	byteCopied := copy(buf, m.intBuf)
-	klog.V(7).Infof("stream.Read bytes copied: %d\n", byteCopied)
-	return buf, nil
+	samplesCopied := copy(dst, m.intBuf)
+	klog.V(7).Infof("stream.Read samples copied: %d\n", samplesCopied)
+	return samplesCopied, nil
 }
 
-// Stream is a helper function to stream the mic data to a source
+// Stream is a helper function to stream the mic data to a source. Capture happens on
+// a separate goroutine and is handed over through a bounded queue so a slow writer
+// never stalls the device.
 func (m *Microphone) Stream(w io.Writer) error {
-	for {
-		select {
-		case <-m.stopChan:
-			return nil
-		default:
-			err := m.stream.Read()
-			if err != nil {
-				klog.V(1).Infof("stream.Read failed. Err: %v\n", err)
-				return err
-			}
-
-			byteCount, err := w.Write(m.int16ToLittleEndianByte(m.intBuf))
-			if err != nil {
-				klog.V(1).Infof("w.Write failed. Err: %v\n", err)
-				return err
-			}
-			klog.V(7).Infof("io.Writer succeeded. Bytes written: %d\n", byteCount)
+	frames := make(chan *[]byte, m.queueSize)
+	done := make(chan struct{})
+	defer close(done)
+
+	var captureErr error
+	go func() {
+		captureErr = m.capture(frames, done)
+		close(frames)
+	}()
+
+	for buf := range frames {
+		byteCount, err := w.Write(*buf)
+		m.putBuffer(buf)
+		if err != nil {
+			klog.V(1).Infof("w.Write failed. Err: %v\n", err)
+			return err
 		}
+		atomic.AddUint64(&m.counters.written, 1)
+		klog.V(7).Infof("io.Writer succeeded. Bytes written: %d\n", byteCount)
 	}
 
-	return nil
+	return captureErr
+}
+
+// Stats returns the pipeline counters
+func (m *Microphone) Stats() MicrophoneStats {
+	return MicrophoneStats{
+		FramesCaptured: atomic.LoadUint64(&m.counters.captured),
+		FramesWritten:  atomic.LoadUint64(&m.counters.written),
+		FramesDropped:  atomic.LoadUint64(&m.counters.dropped),
+	}
+}
+
+// IsSpeaking returns whether voice activity is detected. Always false when detection is disabled.
+func (m *Microphone) IsSpeaking() bool {
+	if m.detector == nil {
+		return false
+	}
+	return m.detector.Active()
 }
 
 // Mute silences the mic
@@ -116,10 +275,16 @@ func (m *Microphone) Unmute() {
 
 // Stop terminates listening on the mic
 func (m *Microphone) Stop() error {
-	err := m.stream.Stop()
-	if err != nil {
-		klog.V(1).Infof("stream.Stop failed. Err: %v\n", err)
-		return err
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
+
+	// stream is nil while recovering from a lost device
+	if m.stream != nil {
+		err := m.stream.Stop()
+		if err != nil {
+			klog.V(1).Infof("stream.Stop failed. Err: %v\n", err)
+			return err
+		}
 	}
 
 	close(m.stopChan)
@@ -128,21 +293,8 @@ func (m *Microphone) Stop() error {
 	return nil
 }
 
-func (m *Microphone) int16ToLittleEndianByte(f []int16) []byte {
+func (m *Microphone) isMuted() bool {
 	m.mute.Lock()
-	isMuted := m.muted
-	m.mute.Unlock()
-
-	if isMuted {
-		klog.V(7).Infof("Mic is MUTED!\n")
-		f = make([]int16, len(f))
-	}
-
-	var buf bytes.Buffer
-	err := binary.Write(&buf, binary.LittleEndian, f)
-	if err != nil {
-		klog.V(1).Infof("binary.Write failed. Err %v\n", err)
-	}
-
-	return buf.Bytes()
+	defer m.mute.Unlock()
+	return m.muted
 }
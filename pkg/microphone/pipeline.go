@@ -0,0 +1,151 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+
+	klog "k8s.io/klog/v2"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// capture reads from the device and queues frames for the writer until stopped
+func (m *Microphone) capture(frames chan *[]byte, done chan struct{}) error {
+	for {
+		select {
+		case <-m.stopChan:
+			return nil
+		case <-done:
+			return nil
+		default:
+			err := m.stream.Read()
+			if err == portaudio.InputOverflowed {
+				klog.V(3).Infof("stream.Read overflowed. Some audio was lost.\n")
+			} else if err != nil {
+				klog.V(1).Infof("stream.Read failed. Err: %v\n", err)
+				if !m.recover(err, done) {
+					return nil
+				}
+				continue
+			}
+			atomic.AddUint64(&m.counters.captured, 1)
+
+			// metering sees the real audio even when muted
+			m.measure(m.intBuf)
+
+			// voice activity sees the real audio even when muted
+			speaking := true
+			if m.detector != nil {
+				speaking = m.detector.Process(m.intBuf)
+			}
+			gated := m.detector != nil && m.detector.Gate() && !speaking
+			muted := m.isMuted()
+
+			buf := m.getBuffer(len(m.intBuf) * 2)
+			int16ToLittleEndianByte(*buf, m.intBuf)
+
+			if muted || gated {
+				// hold on to what the transcriber did not hear
+				if m.preRoll != nil {
+					m.preRoll.Write(*buf)
+				}
+				m.listening = false
+
+				if gated {
+					klog.V(7).Infof("No speech detected. Skipping write.\n")
+					m.putBuffer(buf)
+					continue
+				}
+
+				klog.V(7).Infof("Mic is MUTED!\n")
+				for i := range *buf {
+					(*buf)[i] = 0
+				}
+			} else if !m.listening {
+				m.listening = true
+
+				if m.preRoll != nil && m.preRoll.Len() > 0 {
+					preBuf := m.getBuffer(m.preRoll.Len())
+					m.preRoll.CopyTo(*preBuf)
+					m.preRoll.Reset()
+
+					klog.V(5).Infof("Pre-roll flushed. Bytes queued: %d\n", len(*preBuf))
+					if !m.enqueue(frames, preBuf, done) {
+						return nil
+					}
+				}
+			}
+
+			if !m.enqueue(frames, buf, done) {
+				return nil
+			}
+		}
+	}
+}
+
+// enqueue hands a frame to the writer according to the drop policy. Returns false when stopped.
+func (m *Microphone) enqueue(frames chan *[]byte, buf *[]byte, done chan struct{}) bool {
+	switch m.policy {
+	case Block:
+		select {
+		case frames <- buf:
+		case <-m.stopChan:
+			m.putBuffer(buf)
+			return false
+		case <-done:
+			m.putBuffer(buf)
+			return false
+		}
+	case DropNewest:
+		select {
+		case frames <- buf:
+		default:
+			klog.V(4).Infof("Writer is behind. Dropping newest frame.\n")
+			atomic.AddUint64(&m.counters.dropped, 1)
+			m.putBuffer(buf)
+		}
+	default:
+		for {
+			select {
+			case frames <- buf:
+				return true
+			default:
+			}
+
+			select {
+			case old := <-frames:
+				klog.V(4).Infof("Writer is behind. Dropping oldest frame.\n")
+				atomic.AddUint64(&m.counters.dropped, 1)
+				m.putBuffer(old)
+			default:
+			}
+		}
+	}
+
+	return true
+}
+
+// getBuffer returns a pooled buffer of exactly size bytes
+func (m *Microphone) getBuffer(size int) *[]byte {
+	buf := m.pool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = (*buf)[:size]
+	return buf
+}
+
+func (m *Microphone) putBuffer(buf *[]byte) {
+	m.pool.Put(buf)
+}
+
+// int16ToLittleEndianByte encodes samples into dst which must hold 2 bytes per sample
+func int16ToLittleEndianByte(dst []byte, src []int16) {
+	for i, sample := range src {
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(sample))
+	}
+}
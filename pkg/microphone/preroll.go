@@ -0,0 +1,68 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"time"
+)
+
+// newPreRoll creates a ring buffer holding duration worth of audio
+func newPreRoll(duration time.Duration, channels int, rate float32) *preRoll {
+	frameSize := channels * 2
+	frames := int(duration.Seconds() * float64(rate))
+
+	return &preRoll{
+		buf: make([]byte, frames*frameSize),
+	}
+}
+
+// Write appends audio, overwriting the oldest audio once full
+func (p *preRoll) Write(data []byte) {
+	capacity := len(p.buf)
+	if capacity == 0 {
+		return
+	}
+
+	// only the tail fits
+	if len(data) >= capacity {
+		copy(p.buf, data[len(data)-capacity:])
+		p.start = 0
+		p.size = capacity
+		return
+	}
+
+	end := (p.start + p.size) % capacity
+	n := copy(p.buf[end:], data)
+	copy(p.buf, data[n:])
+
+	p.size += len(data)
+	if p.size > capacity {
+		p.start = (p.start + p.size - capacity) % capacity
+		p.size = capacity
+	}
+}
+
+// Len returns the number of buffered bytes
+func (p *preRoll) Len() int {
+	return p.size
+}
+
+// CopyTo copies the buffered audio oldest first into dst which must hold Len bytes
+func (p *preRoll) CopyTo(dst []byte) int {
+	end := p.start + p.size
+	if end > len(p.buf) {
+		end = len(p.buf)
+	}
+	n := copy(dst, p.buf[p.start:end])
+	n += copy(dst[n:], p.buf[:p.size-n])
+
+	return n
+}
+
+// Reset discards the buffered audio
+func (p *preRoll) Reset() {
+	p.start = 0
+	p.size = 0
+}
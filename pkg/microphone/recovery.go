@@ -0,0 +1,111 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package microphone
+
+import (
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// recover reopens the device with backoff after a read failure. Returns false when
+// stopped before the device came back.
+func (m *Microphone) recover(readErr error, done chan struct{}) bool {
+	lostAt := time.Now()
+	klog.V(1).Infof("Microphone lost. Attempting to recover...\n")
+
+	if m.options.DeviceStatus != nil {
+		err := (*m.options.DeviceStatus).DeviceLost(readErr)
+		if err != nil {
+			klog.V(1).Infof("DeviceLost failed. Err: %v\n", err)
+		}
+	}
+
+	backoff := m.options.RecoveryBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-m.stopChan:
+			return false
+		case <-done:
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := m.reopen()
+		if err == nil {
+			break
+		}
+		klog.V(3).Infof("Reopen attempt %d failed. Err: %v\n", attempt, err)
+
+		backoff *= 2
+		if backoff > m.options.MaxRecoveryBackoff {
+			backoff = m.options.MaxRecoveryBackoff
+		}
+	}
+
+	// stopped while reopening
+	select {
+	case <-m.stopChan:
+		return false
+	default:
+	}
+
+	downtime := time.Since(lostAt)
+	klog.V(1).Infof("Microphone recovered after %v\n", downtime)
+
+	if m.detector != nil {
+		m.detector.Reset()
+	}
+	if m.preRoll != nil {
+		m.preRoll.Reset()
+	}
+
+	if m.options.DeviceStatus != nil {
+		err := (*m.options.DeviceStatus).DeviceRecovered(downtime)
+		if err != nil {
+			klog.V(1).Infof("DeviceRecovered failed. Err: %v\n", err)
+		}
+	}
+
+	return true
+}
+
+// reopen closes the failed stream and opens the device again. PortAudio only
+// rescans devices once fully terminated so the library is restarted as well.
+func (m *Microphone) reopen() error {
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
+
+	select {
+	case <-m.stopChan:
+		return nil
+	default:
+	}
+
+	if m.stream != nil {
+		m.stream.Close()
+		m.stream = nil
+	}
+
+	err := reinitialize()
+	if err != nil {
+		return err
+	}
+
+	stream, err := m.openStream()
+	if err != nil {
+		return err
+	}
+
+	err = stream.Start()
+	if err != nil {
+		klog.V(1).Infof("stream.Start failed. Err: %v\n", err)
+		stream.Close()
+		return err
+	}
+
+	m.stream = stream
+	return nil
+}
@@ -6,24 +6,143 @@ package microphone
 
 import (
 	"sync"
+	"time"
 
 	"github.com/gordonklaus/portaudio"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	vad "github.com/dvonthenen/open-virtual-assistant/pkg/audio/vad"
 )
 
 // AudioConfig init config for library
 type AudioConfig struct {
 	InputChannels int
 	SamplingRate  float32
+
+	// InputDevice selects the device by index or case-insensitive name substring.
+	// Empty uses the default input device.
+	InputDevice string
+
+	// VoiceActivity enables voice activity detection when not nil
+	VoiceActivity *vad.VADConfig
+
+	// PreRoll how much audio to keep while muted or gated and replay once listening resumes
+	PreRoll time.Duration
+
+	// QueueSize how many frames can be waiting on the writer. Defaults to DefaultQueueSize.
+	QueueSize int
+	// DropPolicy what to do when the writer falls behind
+	DropPolicy DropPolicy
+
+	// RecoveryBackoff initial wait between attempts to reopen a failed device,
+	// doubling up to MaxRecoveryBackoff
+	RecoveryBackoff    time.Duration
+	MaxRecoveryBackoff time.Duration
+
+	// DeviceStatus is notified when the device is lost and recovered
+	DeviceStatus *interfaces.DeviceStatusCallback
+}
+
+// DropPolicy what happens to captured audio when the queue to the writer is full
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest queued frame, keeping latency low
+	DropOldest DropPolicy = iota
+	// DropNewest discards the frame just captured
+	DropNewest
+	// Block waits for the writer, which can overflow the device buffer
+	Block
+)
+
+// MicrophoneStats pipeline counters in frames (one device read each)
+type MicrophoneStats struct {
+	FramesCaptured uint64
+	FramesWritten  uint64
+	FramesDropped  uint64
+}
+
+// LevelEvent audio levels and input quality for a single frame. Levels are absolute
+// sample values with dBFS equivalents.
+type LevelEvent struct {
+	Timestamp time.Time
+
+	RMS            float64
+	RMSDBFS        float64
+	Peak           float64
+	PeakDBFS       float64
+	ClippingRatio  float64
+	NoiseFloor     float64
+	NoiseFloorDBFS float64
+
+	// Clipping too many samples are at full scale, lower the gain
+	Clipping bool
+	// DeadMic the device has produced no signal for DeadMicDuration
+	DeadMic bool
+}
+
+// meter level metering state and subscribers
+type meter struct {
+	mu          sync.Mutex
+	subscribers map[int]chan LevelEvent
+	nextID      int
+
+	noiseFloor float64
+	silentFor  time.Duration
+}
+
+// counters kept in their own allocation for 64-bit atomic alignment on 32-bit platforms
+type counters struct {
+	captured uint64
+	written  uint64
+	dropped  uint64
+}
+
+// preRoll ring buffer of little endian PCM
+type preRoll struct {
+	buf   []byte
+	start int
+	size  int
+}
+
+// InputDevice describes an available input device
+type InputDevice struct {
+	Index                int
+	Name                 string
+	HostApi              string
+	MaxInputChannels     int
+	DefaultSampleRate    float64
+	SupportedSampleRates []float64
+	IsDefault            bool
 }
 
 // Microphone...
 type Microphone struct {
+	options *AudioConfig
+
 	// microphone
-	stream *portaudio.Stream
+	stream     *portaudio.Stream
+	streamLock sync.Mutex
 
 	// buffer
 	intBuf []int16
 
+	// voice activity
+	detector *vad.Detector
+
+	// audio not heard by the transcriber
+	preRoll   *preRoll
+	listening bool
+
+	// capture to writer pipeline
+	pool      sync.Pool
+	queueSize int
+	policy    DropPolicy
+	counters  *counters
+
+	// level metering
+	meter meter
+
 	// operational
 	stopChan chan struct{}
 	mute     sync.Mutex
@@ -117,6 +117,11 @@ func (sc *Client) Write(stream []byte) (int, error) {
 }
 
 func (sc *Client) PlayAudio(stream []byte) error {
+	return sc.PlayAudioWithContext(context.Background(), stream)
+}
+
+// PlayAudioWithContext plays the audio and blocks until finished or the context is canceled
+func (sc *Client) PlayAudioWithContext(ctx context.Context, stream []byte) error {
 	klog.V(6).Infof("Client.PlayAudio ENTER\n")
 
 	stringReader := bytes.NewReader(stream)
@@ -137,13 +142,20 @@ func (sc *Client) PlayAudio(stream []byte) error {
 
 	speechData := buffer.Streamer(0, buffer.Len())
 
-	done := make(chan bool)
+	done := make(chan bool, 1)
 	speaker.Play(beep.Seq(speechData, beep.Callback(func() {
 		done <- true
 	})))
 
-	// wait until done... blocking!
-	<-done
+	// wait until done or interrupted... blocking!
+	select {
+	case <-done:
+	case <-ctx.Done():
+		speaker.Clear()
+		klog.V(3).Infof("Playback interrupted\n")
+		klog.V(6).Infof("Client.PlayAudio LEAVE\n")
+		return ctx.Err()
+	}
 
 	klog.V(4).Infof("PlayAudio Succeeded\n")
 	klog.V(6).Infof("Client.PlayAudio LEAVE\n")
@@ -161,7 +173,7 @@ func (sc *Client) Play(ctx context.Context, text string) error {
 		return err
 	}
 
-	err = sc.PlayAudio(stream)
+	err = sc.PlayAudioWithContext(ctx, stream)
 	if err != nil {
 		klog.V(1).Infof("PlayAudio Failed. Err: %v\n", err)
 		klog.V(6).Infof("Client.Play LEAVE\n")
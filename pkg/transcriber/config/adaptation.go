@@ -0,0 +1,74 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"regexp"
+)
+
+// Phrase a phrase the recognizer should favor. The phrase can reference a PhraseClass
+// using ${name}. A Boost of zero leaves the weight up to the backend.
+type Phrase struct {
+	Value string
+	Boost float32
+}
+
+// PhraseClass a named group of interchangeable words, like the different ways to say hello
+type PhraseClass struct {
+	Name   string
+	Values []string
+}
+
+// SpeechAdaptation backend neutral boosting which each transcriber translates into
+// its own format. An empty, non-nil SpeechAdaptation disables boosting.
+type SpeechAdaptation struct {
+	Phrases []Phrase
+	Classes []PhraseClass
+}
+
+var classRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Expand returns the phrases with every class reference replaced by each of the
+// class's values, for backends which only understand plain keywords. References
+// to unknown classes are left as they are.
+func (a *SpeechAdaptation) Expand() []Phrase {
+	classes := make(map[string][]string)
+	for _, c := range a.Classes {
+		classes[c.Name] = c.Values
+	}
+
+	var expanded []Phrase
+	seen := make(map[string]bool)
+	for _, phrase := range a.Phrases {
+		for _, value := range expandValue(phrase.Value, classes) {
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			expanded = append(expanded, Phrase{Value: value, Boost: phrase.Boost})
+		}
+	}
+	return expanded
+}
+
+func expandValue(value string, classes map[string][]string) []string {
+	loc := classRef.FindStringSubmatchIndex(value)
+	if loc == nil {
+		return []string{value}
+	}
+
+	values, ok := classes[value[loc[2]:loc[3]]]
+	if !ok || len(values) == 0 {
+		values = []string{value[loc[0]:loc[1]]}
+	}
+
+	var result []string
+	for _, v := range values {
+		for _, rest := range expandValue(value[loc[1]:], classes) {
+			result = append(result, value[:loc[0]]+v+rest)
+		}
+	}
+	return result
+}
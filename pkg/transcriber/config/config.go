@@ -5,12 +5,162 @@
 package config
 
 import (
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	recorder "github.com/dvonthenen/open-virtual-assistant/pkg/audio/recorder"
+	vad "github.com/dvonthenen/open-virtual-assistant/pkg/audio/vad"
 	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	postprocess "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/postprocess"
 )
 
 type TranscribeOptions struct {
 	InputChannels int
 	SamplingRate  int
 
-	Callback *interfaces.ResponseCallback
+	// InputDevice selects the microphone by index or name substring
+	InputDevice string
+
+	// Source is the audio to transcribe. If nil, the default microphone is used.
+	Source *audio.AudioSource
+
+	// VoiceActivity enables voice activity detection on the default microphone
+	VoiceActivity *vad.VADConfig
+
+	// PreRoll how much audio the default microphone replays when listening resumes
+	PreRoll time.Duration
+
+	// DeviceStatus is notified when the default microphone is lost and recovered
+	DeviceStatus *audio.DeviceStatusCallback
+
+	// Language is the BCP-47 code of the language to recognize. Defaults to en-US.
+	Language string
+	// AlternativeLanguages other languages the speaker might use. Backends which can
+	// detect the language report the one which was spoken on each transcript.
+	AlternativeLanguages []string
+	// DetectLanguage asks the backend to work out the language on its own
+	DetectLanguage bool
+
+	// Segmentation decides where one utterance ends and the next begins. Nil leaves it
+	// up to the backend.
+	Segmentation *Segmentation
+
+	// Adaptation boosts phrases the assistant expects to hear, like its name. When nil,
+	// each backend falls back to its own defaults.
+	Adaptation *SpeechAdaptation
+
+	// PostProcess cleans up transcript text before it is delivered. Nil lowercases it,
+	// an empty pipeline leaves the text as the backend returned it.
+	PostProcess *postprocess.Pipeline
+
+	// ConnectionStatus is notified when the backend connection drops and comes back
+	ConnectionStatus *interfaces.ConnectionStatusCallback
+
+	// Recorder saves the audio sent to the transcriber and the final transcripts
+	Recorder *recorder.Recorder
+
+	// TranscriptCallback receives rich transcripts and takes precedence over Callback
+	TranscriptCallback *interfaces.TranscriptCallback
+	Callback           *interfaces.ResponseCallback
+
+	// PartialCallback opts into interim results. Final transcripts are still delivered
+	// to TranscriptCallback or Callback.
+	PartialCallback *interfaces.PartialTranscriptCallback
+
+	// UtteranceEndCallback is told when each utterance is over
+	UtteranceEndCallback *interfaces.UtteranceEndCallback
+}
+
+// GetTranscriptCallback returns the callback transcripts should be delivered to,
+// adapting Callback when TranscriptCallback is not set. Returns nil when neither is set.
+// The text is run through PostProcess on the way.
+func (o *TranscribeOptions) GetTranscriptCallback() interfaces.TranscriptCallback {
+	var callback interfaces.TranscriptCallback
+	switch {
+	case o.TranscriptCallback != nil:
+		callback = *o.TranscriptCallback
+	case o.Callback != nil:
+		callback = &interfaces.ResponseAdapter{Callback: *o.Callback}
+	default:
+		return nil
+	}
+
+	pipeline := o.GetPostProcess()
+	if len(pipeline.Stages) == 0 {
+		return callback
+	}
+	return postprocess.TranscriptCallback(pipeline, callback)
+}
+
+// GetPartialCallback returns the callback for interim results or nil when they are not wanted
+func (o *TranscribeOptions) GetPartialCallback() interfaces.PartialTranscriptCallback {
+	if o.PartialCallback == nil {
+		return nil
+	}
+
+	pipeline := o.GetPostProcess()
+	if len(pipeline.Stages) == 0 {
+		return *o.PartialCallback
+	}
+	return postprocess.PartialCallback(pipeline, *o.PartialCallback)
+}
+
+// GetPostProcess returns the pipeline transcripts go through, the default when none is set
+func (o *TranscribeOptions) GetPostProcess() *postprocess.Pipeline {
+	if o.PostProcess == nil {
+		return postprocess.Default()
+	}
+	return o.PostProcess
+}
+
+// ConnectionLost notifies ConnectionStatus, if set
+func (o *TranscribeOptions) ConnectionLost(backend string, err error) {
+	if o.ConnectionStatus == nil {
+		return
+	}
+	errCallback := (*o.ConnectionStatus).ConnectionLost(backend, err)
+	if errCallback != nil {
+		klog.V(1).Infof("ConnectionStatus.ConnectionLost failed. Err: %v\n", errCallback)
+	}
+}
+
+// ConnectionRestored notifies ConnectionStatus, if set
+func (o *TranscribeOptions) ConnectionRestored(backend string, downtime time.Duration) {
+	if o.ConnectionStatus == nil {
+		return
+	}
+	err := (*o.ConnectionStatus).ConnectionRestored(backend, downtime)
+	if err != nil {
+		klog.V(1).Infof("ConnectionStatus.ConnectionRestored failed. Err: %v\n", err)
+	}
+}
+
+// BackendSwitched notifies ConnectionStatus, if set, of a switch between backends.
+// Falls back to ConnectionRestored for the new backend.
+func (o *TranscribeOptions) BackendSwitched(from, to string, downtime time.Duration) {
+	if o.ConnectionStatus == nil {
+		return
+	}
+	switcher, ok := (*o.ConnectionStatus).(interfaces.BackendSwitchCallback)
+	if !ok {
+		o.ConnectionRestored(to, downtime)
+		return
+	}
+	err := switcher.BackendSwitched(from, to, downtime)
+	if err != nil {
+		klog.V(1).Infof("ConnectionStatus.BackendSwitched failed. Err: %v\n", err)
+	}
+}
+
+// UtteranceEnded notifies UtteranceEndCallback, if set
+func (o *TranscribeOptions) UtteranceEnded(utteranceID string, channel int) {
+	if o.UtteranceEndCallback == nil {
+		return
+	}
+	err := (*o.UtteranceEndCallback).UtteranceEnd(utteranceID, channel)
+	if err != nil {
+		klog.V(1).Infof("UtteranceEndCallback.UtteranceEnd failed. Err: %v\n", err)
+	}
 }
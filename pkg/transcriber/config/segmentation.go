@@ -0,0 +1,23 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"time"
+)
+
+// Segmentation backend neutral settings for ending an utterance. Backends map what
+// they can onto their own endpointing and fall back to merging fragments locally.
+type Segmentation struct {
+	// SilenceTimeout how long the speaker has to pause before their turn is over
+	SilenceTimeout time.Duration
+
+	// MaxUtterance ends a turn which goes on for longer than this. Zero means no limit.
+	MaxUtterance time.Duration
+
+	// LocalAggregation always merges the backend's fragments locally, even when the
+	// backend can end utterances on its own
+	LocalAggregation bool
+}
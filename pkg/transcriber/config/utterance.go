@@ -0,0 +1,22 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	utteranceEpoch   = time.Now().Unix()
+	utteranceCounter uint64
+)
+
+// NewUtteranceID returns an ID which is unique across transcribers in this process
+// and unlikely to repeat between runs
+func NewUtteranceID() string {
+	return fmt.Sprintf("%x-%d", utteranceEpoch, atomic.AddUint64(&utteranceCounter, 1))
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package deepgram
+
+import (
+	"fmt"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+)
+
+// DefaultAdaptation is used when TranscribeOptions.Adaptation is nil
+var DefaultAdaptation = config.SpeechAdaptation{
+	Phrases: []config.Phrase{
+		{Value: "Hey Kitt", Boost: 32},
+		{Value: "Hey Kit", Boost: 16},
+		{Value: "Hey", Boost: 16},
+		{Value: "Hello", Boost: 16},
+		{Value: "Kitt", Boost: 16},
+		{Value: "Kit", Boost: 16},
+	},
+}
+
+// toKeywords translates the backend neutral adaptation into Deepgram keywords.
+// Deepgram has no notion of classes so they are expanded into plain phrases.
+func toKeywords(adaptation *config.SpeechAdaptation) []string {
+	if adaptation == nil {
+		adaptation = &DefaultAdaptation
+	}
+
+	var keywords []string
+	for _, phrase := range adaptation.Expand() {
+		if phrase.Boost == 0 {
+			keywords = append(keywords, phrase.Value)
+			continue
+		}
+		keywords = append(keywords, fmt.Sprintf("%s:%g", phrase.Value, phrase.Boost))
+	}
+	return keywords
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package deepgram
+
+import (
+	"errors"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	live "github.com/deepgram/deepgram-go-sdk/pkg/client/live"
+
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+var (
+	// ErrConnectFailed the websocket connection to Deepgram could not be established
+	ErrConnectFailed = errors.New("deepgram connection failed")
+)
+
+// newClient creates a client sharing the message handler so an utterance in
+// progress survives a reconnect
+func (a *Transcribe) newClient() (*live.Client, error) {
+	client, err := live.New(a.ctx, "", &live.ClientOptions{}, a.liveOptions, a.handler)
+	if err != nil {
+		klog.V(1).Infof("live.New failed. Err: %v\n", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// Write sends audio to Deepgram. While reconnecting the audio is buffered instead and
+// replayed once the connection is back, so the audio source never sees an error.
+func (a *Transcribe) Write(buf []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastWrite = time.Now()
+
+	if a.state == tinterfaces.ConnectionReconnecting {
+		a.buffer(buf)
+		return len(buf), nil
+	}
+
+	_, err := a.client.Write(buf)
+	if err != nil {
+		klog.V(1).Infof("client.Write failed. Err: %v\n", err)
+		a.buffer(buf)
+		a.connectionLostLocked(err)
+	}
+
+	return len(buf), nil
+}
+
+// keepAlive stops Deepgram from closing the connection while the source is muted
+// or gated and no audio is being sent. A failed keepalive means the connection is gone.
+func (a *Transcribe) keepAlive() {
+	ticker := time.NewTicker(DefaultKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.state == tinterfaces.ConnectionConnected && time.Since(a.lastWrite) >= DefaultKeepAliveInterval {
+				klog.V(5).Infof("Sending Deepgram KeepAlive\n")
+				err := a.client.WriteJSON(map[string]string{"type": "KeepAlive"})
+				if err != nil {
+					klog.V(1).Infof("client.WriteJSON failed. Err: %v\n", err)
+					a.connectionLostLocked(err)
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// buffer keeps audio for replay, dropping the oldest audio past DefaultMaxBuffered.
+// Must be called with a.mu held.
+func (a *Transcribe) buffer(buf []byte) {
+	cp := make([]byte, len(buf))
+	copy(cp, buf)
+	a.pending = append(a.pending, cp)
+	a.pendingBytes += len(cp)
+
+	for a.pendingBytes > a.maxPendingBytes && len(a.pending) > 1 {
+		a.pendingBytes -= len(a.pending[0])
+		a.pending = a.pending[1:]
+	}
+}
+
+// connectionLostLocked switches to buffering and starts reconnecting. Must be called with a.mu held.
+func (a *Transcribe) connectionLostLocked(err error) {
+	if a.state == tinterfaces.ConnectionReconnecting || a.ctx.Err() != nil {
+		return
+	}
+
+	klog.V(1).Infof("Deepgram connection lost. Err: %v\n", err)
+	a.state = tinterfaces.ConnectionReconnecting
+	a.lostAt = time.Now()
+
+	go func() {
+		a.options.ConnectionLost(tinterfaces.DEEPGRAM_TRANSCRIBER, err)
+		a.reconnect()
+	}()
+}
+
+// reconnect replaces the client with backoff until it succeeds or the transcriber is stopped
+func (a *Transcribe) reconnect() {
+	backoff := DefaultReconnectBackoff
+
+	// the old client keeps trying on its own, make it stop
+	a.mu.Lock()
+	old := a.client
+	a.mu.Unlock()
+	go old.Stop()
+
+	for {
+		if a.ctx.Err() != nil {
+			return
+		}
+
+		err := a.tryReconnect()
+		if err == nil {
+			return
+		}
+
+		klog.V(2).Infof("Deepgram reconnect failed. Err: %v. Retrying in %v\n", err, backoff)
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > DefaultMaxReconnectBackoff {
+			backoff = DefaultMaxReconnectBackoff
+		}
+	}
+}
+
+func (a *Transcribe) tryReconnect() error {
+	client, err := a.newClient()
+	if err != nil {
+		return err
+	}
+
+	// a single attempt, the backoff is ours
+	if client.AttemptReconnect(1) == nil {
+		client.Stop()
+		return ErrConnectFailed
+	}
+
+	a.mu.Lock()
+	for _, buf := range a.pending {
+		_, err = client.Write(buf)
+		if err != nil {
+			a.mu.Unlock()
+			klog.V(1).Infof("replaying audio failed. Err: %v\n", err)
+			client.Stop()
+			return err
+		}
+	}
+	replayed := a.pendingBytes
+
+	a.client = client
+	a.pending = nil
+	a.pendingBytes = 0
+	a.state = tinterfaces.ConnectionConnected
+	downtime := time.Since(a.lostAt)
+	a.mu.Unlock()
+
+	klog.V(2).Infof("Deepgram reconnected after %v. Replayed %d bytes\n", downtime, replayed)
+	a.options.ConnectionRestored(tinterfaces.DEEPGRAM_TRANSCRIBER, downtime)
+
+	return nil
+}
+
+// ConnectionState implements tinterfaces.ConnectionReporter
+func (a *Transcribe) ConnectionState() tinterfaces.ConnectionState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state
+}
@@ -7,25 +7,80 @@ package deepgram
 import (
 	"context"
 	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
 
 	klog "k8s.io/klog/v2"
 
 	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
 	live "github.com/deepgram/deepgram-go-sdk/pkg/client/live"
 
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
 	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
 	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+	segmenter "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/segmenter"
+)
+
+const (
+	// DefaultKeepAliveInterval Deepgram closes connections which go ten seconds without audio
+	DefaultKeepAliveInterval = 4 * time.Second
+
+	// DefaultReconnectBackoff is the first wait between reconnect attempts, doubling
+	// up to DefaultMaxReconnectBackoff
+	DefaultReconnectBackoff    = 500 * time.Millisecond
+	DefaultMaxReconnectBackoff = 10 * time.Second
+
+	// DefaultMaxBuffered is how much audio is kept for replay while reconnecting
+	DefaultMaxBuffered = 30 * time.Second
 )
 
 type Transcribe struct {
 	options *config.TranscribeOptions
 
-	client *live.Client
-	mic    *microphone.Microphone
+	liveOptions interfaces.LiveTranscriptionOptions
+	handler     *Insights
+	segmenter   *segmenter.Segmenter
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+
+	// connection, replaced on reconnect
+	mu              sync.Mutex
+	client          *live.Client
+	state           tinterfaces.ConnectionState
+	lastWrite       time.Time
+	lostAt          time.Time
+	pending         [][]byte
+	pendingBytes    int
+	maxPendingBytes int
+
+	source  audio.AudioSource
+	ownsMic bool
 }
 
 var micInitAlready = false
 
+func init() {
+	registry.Register(tinterfaces.DEEPGRAM_TRANSCRIBER, func(ctx context.Context, opts *config.TranscribeOptions) (tinterfaces.Transcriber, error) {
+		t, err := New(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+	registry.RegisterFile(tinterfaces.DEEPGRAM_TRANSCRIBER, func(ctx context.Context, opts *config.TranscribeOptions) (tinterfaces.FileTranscriber, error) {
+		t, err := NewFile(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
 func New(ctx context.Context, opts *config.TranscribeOptions) (*Transcribe, error) {
 	klog.V(6).Infof("transcribe.New ENTER\n")
 
@@ -40,52 +95,90 @@ func New(ctx context.Context, opts *config.TranscribeOptions) (*Transcribe, erro
 		ctx = context.Background()
 	}
 
-	// mic stuf
-	if !micInitAlready {
-		klog.V(4).Infof("Calling microphone.Initialize...")
-		microphone.Initialize()
-		micInitAlready = true
+	// audio source, fallback to the default mic
+	var source audio.AudioSource
+	ownsMic := false
+	if opts.Source != nil {
+		klog.V(4).Infof("Using the provided audio source")
+		source = *opts.Source
+	} else {
+		if !micInitAlready {
+			klog.V(4).Infof("Calling microphone.Initialize...")
+			microphone.Initialize()
+			micInitAlready = true
+		}
+
+		mic, err := microphone.New(microphone.AudioConfig{
+			InputChannels: opts.InputChannels,
+			SamplingRate:  float32(opts.SamplingRate),
+			InputDevice:   opts.InputDevice,
+			VoiceActivity: opts.VoiceActivity,
+			PreRoll:       opts.PreRoll,
+			DeviceStatus:  opts.DeviceStatus,
+		})
+		if err != nil {
+			klog.V(1).Infof("New failed. Err: %v\n", err)
+			return nil, err
+		}
+		source = mic
+		ownsMic = true
 	}
 
-	mic, err := microphone.New(microphone.AudioConfig{
-		InputChannels: opts.InputChannels,
-		SamplingRate:  float32(opts.SamplingRate),
-	})
-	if err != nil {
-		klog.V(1).Infof("New failed. Err: %v\n", err)
-		return nil, err
+	if opts.Language == "" {
+		opts.Language = tinterfaces.DefaultLanguageCode
+	}
+	// the live API has no language detection, transcripts report the configured language
+	if opts.DetectLanguage || len(opts.AlternativeLanguages) > 0 {
+		klog.V(2).Infof("Deepgram live does not support language detection. Using %s only\n", opts.Language)
+	}
+
+	// merge fragments locally for what deepgram endpointing cannot do
+	var seg *segmenter.Segmenter
+	if segmenter.Needed(opts, true) {
+		klog.V(4).Infof("Using local segmentation\n")
+		seg = segmenter.New(opts, source)
+		opts = seg.Options()
 	}
 
 	// Deepgram init
 	options := interfaces.LiveTranscriptionOptions{
-		Language:   "en-US",
-		Encoding:   "linear16",
-		Channels:   opts.InputChannels,
-		SampleRate: opts.SamplingRate,
-		Punctuate:  true,
-		Keywords:   []string{"Hey Kitt:32", "Hey Kit:16", "Hey:16", "Hello:16", "Hey:16", "Kitt:16", "Kit:16"},
-		// Endpointing: "500",
+		Language:       opts.Language,
+		Encoding:       "linear16",
+		Channels:       opts.InputChannels,
+		SampleRate:     opts.SamplingRate,
+		Punctuate:      true,
+		Keywords:       toKeywords(opts.Adaptation),
+		InterimResults: opts.PartialCallback != nil,
+	}
+	if opts.Segmentation != nil && opts.Segmentation.SilenceTimeout > 0 {
+		options.Endpointing = strconv.FormatInt(opts.Segmentation.SilenceTimeout.Milliseconds(), 10)
 	}
 	// klog.V(2).Infof("options: %v\n", options)
 
 	handler := NewInsightHandler(&InsightOptions{
 		TranscribeOptions: opts,
-		Microphone:        mic,
+		Source:            source,
+		Language:          options.Language,
 	})
 
+	transcribe := &Transcribe{
+		options:         opts,
+		liveOptions:     options,
+		handler:         handler,
+		segmenter:       seg,
+		source:          source,
+		ownsMic:         ownsMic,
+		maxPendingBytes: int(DefaultMaxBuffered.Seconds()) * opts.SamplingRate * opts.InputChannels * 2,
+	}
+	transcribe.ctx, transcribe.ctxCancel = context.WithCancel(ctx)
+
 	// create a new client
-	client, err := live.NewWithDefaults(ctx, options, handler)
+	client, err := transcribe.newClient()
 	if err != nil {
-		klog.V(1).Infof("NewDeepGramWSClientDefault failed", err)
+		klog.V(1).Infof("newClient failed. Err: %v\n", err)
 		return nil, err
 	}
-
-	// crete client
-	transcribe := &Transcribe{
-		options: opts,
-		client:  client,
-		mic:     mic,
-	}
+	transcribe.client = client
 
 	klog.V(4).Infof("transcribe.New Succeeded\n")
 	klog.V(6).Infof("transcribe.New LEAVE\n")
@@ -100,23 +193,42 @@ func (a *Transcribe) Start() error {
 	wsconn := a.client.Connect()
 	if wsconn == nil {
 		err := errors.New("client.Connect failed")
-		klog.V(1).Infof("transcribe.Start failed", err)
+		klog.V(1).Infof("transcribe.Start failed. Err: %v\n", err)
 		return err
 	}
 	klog.V(4).Infof("client.Connect succeeded")
 
-	// start the mic
-	err := a.mic.Start()
+	a.mu.Lock()
+	a.state = tinterfaces.ConnectionConnected
+	a.lastWrite = time.Now()
+	a.mu.Unlock()
+
+	go a.keepAlive()
+
+	// start the audio source
+	err := a.source.Start()
 	if err != nil {
-		klog.V(1).Infof("mic.Start failed. Err: %v\n", err)
+		klog.V(1).Infof("source.Start failed. Err: %v\n", err)
 		klog.V(6).Infof("transcribe.Start LEAVE\n")
 		return err
 	}
-	klog.V(4).Infof("mic.Start succeeded")
+	klog.V(4).Infof("source.Start succeeded")
+
+	// tee to the recorder
+	var w io.Writer
+	w = a
+	if a.options.Recorder != nil {
+		w = io.MultiWriter(a, a.options.Recorder)
+	}
 
-	// this is a blocking call
+	// this is a blocking call. a source that gives up, like a failed splitter channel,
+	// leaves this transcriber deaf so the owner is told to restart it
 	go func() {
-		a.mic.Stream(a.client)
+		err := a.source.Stream(w)
+		if err != nil && a.ctx.Err() == nil {
+			klog.V(1).Infof("source.Stream failed. Err: %v\n", err)
+			a.options.ConnectionLost(tinterfaces.DEEPGRAM_TRANSCRIBER, err)
+		}
 	}()
 
 	klog.V(4).Infof("transcribe.Start Succeeded\n")
@@ -127,20 +239,34 @@ func (a *Transcribe) Start() error {
 func (a *Transcribe) Stop() error {
 	klog.V(6).Infof("transcribe.Stop ENTER\n")
 
-	// close client
-	a.client.Stop()
+	// stop reconnecting and close client
+	a.ctxCancel()
+
+	a.mu.Lock()
+	a.state = tinterfaces.ConnectionDisconnected
+	client := a.client
+	a.mu.Unlock()
+
+	client.Stop()
 	klog.V(4).Infof("client.Stop succeeded")
 
-	// close mic stream
-	err := a.mic.Stop()
+	// deliver what has been heard so far
+	if a.segmenter != nil {
+		a.segmenter.Close()
+	}
+
+	// close audio source
+	err := a.source.Stop()
 	if err != nil {
-		klog.V(1).Infof("mic.Stop failed. Err: %v\n", err)
+		klog.V(1).Infof("source.Stop failed. Err: %v\n", err)
 	}
-	klog.V(4).Infof("mic.Stop succeeded")
+	klog.V(4).Infof("source.Stop succeeded")
 
 	// microphone teardown
-	klog.V(4).Infof("Calling microphone.Teardown...")
-	microphone.Teardown()
+	if a.ownsMic {
+		klog.V(4).Infof("Calling microphone.Teardown...")
+		microphone.Teardown()
+	}
 
 	klog.V(6).Infof("transcribe.Stop Succeeded\n")
 	klog.V(6).Infof("transcribe.Stop LEAVE\n")
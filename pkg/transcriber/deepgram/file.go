@@ -0,0 +1,156 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package deepgram
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	prerecordedapi "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1"
+	api "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1/interfaces"
+	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
+	prerecorded "github.com/deepgram/deepgram-go-sdk/pkg/client/prerecorded"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	tinterfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+var (
+	// ErrMissingAPIKey DEEPGRAM_API_KEY is not set
+	ErrMissingAPIKey = errors.New("DEEPGRAM_API_KEY not set")
+)
+
+// FileTranscribe transcribes recordings using Deepgram's prerecorded API
+type FileTranscribe struct {
+	options *config.TranscribeOptions
+	client  *prerecordedapi.PrerecordedClient
+}
+
+// NewFile creates a file transcriber using the same language and keywords as the live one
+func NewFile(ctx context.Context, opts *config.TranscribeOptions) (*FileTranscribe, error) {
+	klog.V(6).Infof("transcribe.NewFile ENTER\n")
+
+	if opts.Language == "" {
+		opts.Language = tinterfaces.DefaultLanguageCode
+	}
+
+	client := prerecorded.New("", &prerecorded.ClientOptions{})
+	if client == nil {
+		klog.V(1).Infof("prerecorded.New failed. Err: %v\n", ErrMissingAPIKey)
+		return nil, ErrMissingAPIKey
+	}
+
+	klog.V(6).Infof("transcribe.NewFile LEAVE\n")
+	return &FileTranscribe{
+		options: opts,
+		client:  prerecordedapi.New(client),
+	}, nil
+}
+
+// TranscribeFile implements interfaces.FileTranscriber. WAV and MP3 files are
+// uploaded as they are.
+func (f *FileTranscribe) TranscribeFile(ctx context.Context, filePath string) (*tinterfaces.FileTranscript, error) {
+	klog.V(6).Infof("transcribe.TranscribeFile ENTER\n")
+
+	options := interfaces.PreRecordedTranscriptionOptions{
+		Punctuate:  true,
+		Utterances: true,
+		Keywords:   toKeywords(f.options.Adaptation),
+	}
+	// deepgram either detects the language or uses the one it is given
+	if f.options.DetectLanguage || len(f.options.AlternativeLanguages) > 0 {
+		options.DetectLanguage = true
+	} else {
+		options.Language = f.options.Language
+	}
+	if f.options.Segmentation != nil && f.options.Segmentation.SilenceTimeout > 0 {
+		options.UttSplit = f.options.Segmentation.SilenceTimeout.Seconds()
+	}
+
+	resp, err := f.client.FromFile(ctx, filePath, options)
+	if err != nil {
+		klog.V(1).Infof("client.FromFile failed. Err: %v\n", err)
+		klog.V(6).Infof("transcribe.TranscribeFile LEAVE\n")
+		return nil, err
+	}
+
+	result := f.newFileTranscript(resp)
+	klog.V(3).Infof("Deepgram file transcription: segments = %d, duration = %v\n", len(result.Segments), result.Duration)
+
+	klog.V(6).Infof("transcribe.TranscribeFile LEAVE\n")
+	return result, nil
+}
+
+// Close implements interfaces.FileTranscriber
+func (f *FileTranscribe) Close() error {
+	return nil
+}
+
+// newFileTranscript turns the response into segments, one per utterance
+func (f *FileTranscribe) newFileTranscript(resp *api.PreRecordedResponse) *tinterfaces.FileTranscript {
+	result := &tinterfaces.FileTranscript{
+		Language: f.options.Language,
+		Duration: time.Duration(resp.Metadata.Duration * float64(time.Second)),
+		Backend:  tinterfaces.DEEPGRAM_TRANSCRIBER,
+	}
+	for _, channel := range resp.Results.Channels {
+		if channel.DetectedLanguage != "" {
+			result.Language = channel.DetectedLanguage
+			break
+		}
+	}
+
+	pipeline := f.options.GetPostProcess()
+	lines := make([]string, 0, len(resp.Results.Utterances))
+
+	for _, utterance := range resp.Results.Utterances {
+		words := convertPrerecordedWords(utterance.Words)
+
+		transcript := pipeline.Apply(&tinterfaces.Transcript{
+			Text:       strings.TrimSpace(utterance.Transcript),
+			Confidence: utterance.Confidence,
+			Words:      words,
+			Alternatives: []tinterfaces.Alternative{
+				{Text: strings.TrimSpace(utterance.Transcript), Confidence: utterance.Confidence, Words: words},
+			},
+			Language:    result.Language,
+			Channel:     utterance.Channel,
+			IsFinal:     true,
+			UtteranceID: utterance.ID,
+			Backend:     tinterfaces.DEEPGRAM_TRANSCRIBER,
+			Timestamp:   time.Now(),
+		})
+		if transcript.Text == "" {
+			continue
+		}
+
+		result.Segments = append(result.Segments, tinterfaces.Segment{
+			Transcript: *transcript,
+			Start:      time.Duration(utterance.Start * float64(time.Second)),
+			End:        time.Duration(utterance.End * float64(time.Second)),
+		})
+		lines = append(lines, transcript.Text)
+	}
+	result.Text = strings.Join(lines, "\n")
+
+	return result
+}
+
+func convertPrerecordedWords(words []api.Word) []tinterfaces.Word {
+	converted := make([]tinterfaces.Word, 0, len(words))
+	for _, w := range words {
+		converted = append(converted, tinterfaces.Word{
+			Word:       w.Word,
+			Start:      time.Duration(w.Start * float64(time.Second)),
+			End:        time.Duration(w.End * float64(time.Second)),
+			Confidence: w.Confidence,
+		})
+	}
+	return converted
+}
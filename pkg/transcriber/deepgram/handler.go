@@ -6,24 +6,34 @@ package deepgram
 
 import (
 	"strings"
+	"time"
 
 	klog "k8s.io/klog/v2"
 
 	api "github.com/deepgram/deepgram-go-sdk/pkg/api/live/v1/interfaces"
 
-	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
 	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
 )
 
 type InsightOptions struct {
 	TranscribeOptions *config.TranscribeOptions
-	Microphone        *microphone.Microphone
+	Source            audio.AudioSource
+	Language          string
 }
 
 type Insights struct {
 	options *InsightOptions
 
-	sb strings.Builder
+	// fragments of the current utterance
+	sb            strings.Builder
+	words         []interfaces.Word
+	alternatives  []interfaces.Alternative
+	confidenceSum float64
+	fragments     int
+	utteranceID   string
+	startedAt     time.Time
 }
 
 func NewInsightHandler(opts *InsightOptions) *Insights {
@@ -33,6 +43,11 @@ func NewInsightHandler(opts *InsightOptions) *Insights {
 }
 
 func (i *Insights) Message(mr *api.MessageResponse) error {
+	if len(mr.Channel.Alternatives) == 0 {
+		klog.V(7).Infof("DEEPGRAM - no alternatives\n")
+		return nil
+	}
+
 	klog.V(5).Infof("\n\n")
 	klog.V(5).Infof("---------------------------------------------\n")
 	klog.V(5).Infof("recv: %s\n", mr.Channel.Alternatives[0].Transcript)
@@ -42,36 +57,164 @@ func (i *Insights) Message(mr *api.MessageResponse) error {
 	klog.V(5).Infof("\n\n")
 
 	sentence := strings.TrimSpace(mr.Channel.Alternatives[0].Transcript)
-
-	if len(mr.Channel.Alternatives) == 0 || len(sentence) == 0 {
+	if len(sentence) == 0 {
+		// endpointing fired after the words already came in with is_final
+		if mr.SpeechFinal && i.sb.Len() > 0 {
+			klog.V(5).Infof("DEEPGRAM - empty speech_final, ending the utterance\n")
+			i.finalize(mr)
+			return nil
+		}
 		klog.V(7).Infof("DEEPGRAM - no transcript\n")
 		return nil
 	}
 
 	isFinal := mr.SpeechFinal
-	sentence = strings.ToLower(sentence)
+	if i.utteranceID == "" {
+		i.utteranceID = config.NewUtteranceID()
+	}
+
+	// interim results are only a preview of the fragment
+	if !mr.IsFinal {
+		preview := sentence
+		if i.sb.Len() > 0 {
+			preview = i.sb.String() + " " + sentence
+		}
+		i.partial(preview, mr)
+		return nil
+	}
+
+	if i.sb.Len() == 0 {
+		i.startedAt = time.Now()
+	} else {
+		i.sb.WriteString(" ")
+	}
 	i.sb.WriteString(sentence)
 
+	// cut off a long winded speaker
+	if seg := i.options.TranscribeOptions.Segmentation; seg != nil && seg.MaxUtterance > 0 && time.Since(i.startedAt) >= seg.MaxUtterance {
+		klog.V(4).Infof("Utterance reached %v, ending it\n", seg.MaxUtterance)
+		isFinal = true
+	}
+
+	best := mr.Channel.Alternatives[0]
+	i.words = append(i.words, convertWords(best.Words)...)
+	i.confidenceSum += best.Confidence
+	i.fragments++
+	i.alternatives = i.alternatives[:0]
+	for _, alt := range mr.Channel.Alternatives {
+		i.alternatives = append(i.alternatives, interfaces.Alternative{
+			Text:       strings.TrimSpace(alt.Transcript),
+			Confidence: alt.Confidence,
+			Words:      convertWords(alt.Words),
+		})
+	}
+
 	// debug
 	klog.V(7).Infof("transcription result: text = %s, final = %t\n", i.sb.String(), isFinal)
 
 	if !isFinal {
 		klog.V(7).Infof("DEEPGRAM - not final\n")
+		i.partial(i.sb.String(), mr)
 		return nil
 	}
 
+	i.finalize(mr)
+	return nil
+}
+
+// finalize delivers the utterance heard so far and starts a new one
+func (i *Insights) finalize(mr *api.MessageResponse) {
 	// debug
-	klog.V(3).Infof("Deepgram transcription: text = %s, final = %t", i.sb.String(), isFinal)
+	klog.V(3).Infof("Deepgram transcription: text = %s\n", i.sb.String())
+
+	transcript := &interfaces.Transcript{
+		Text:        i.sb.String(),
+		Confidence:  i.confidenceSum / float64(i.fragments),
+		Words:       i.words,
+		Language:    i.options.Language,
+		IsFinal:     true,
+		UtteranceID: i.utteranceID,
+		Backend:     interfaces.DEEPGRAM_TRANSCRIBER,
+		Timestamp:   time.Now(),
+	}
+	if len(mr.ChannelIndex) > 0 {
+		transcript.Channel = mr.ChannelIndex[0]
+	}
+
+	// alternatives only line up with the text for single fragment utterances
+	if i.fragments == 1 {
+		transcript.Alternatives = append([]interfaces.Alternative{}, i.alternatives...)
+	} else {
+		transcript.Alternatives = []interfaces.Alternative{
+			{Text: transcript.Text, Confidence: transcript.Confidence, Words: transcript.Words},
+		}
+	}
+
+	// end the recording here, before the reply is handled and spoken
+	if i.options.TranscribeOptions.Recorder != nil {
+		err := i.options.TranscribeOptions.Recorder.Segment(transcript.Text)
+		if err != nil {
+			klog.V(1).Infof("Recorder.Segment failed. Err: %v\n", err)
+		}
+	}
 
 	// perform callback
-	i.options.Microphone.Mute()
-	(*i.options.TranscribeOptions.Callback).Response(i.sb.String())
-	i.options.Microphone.Unmute()
+	if callback := i.options.TranscribeOptions.GetTranscriptCallback(); callback != nil {
+		i.options.Source.Mute()
+		err := callback.Transcript(transcript)
+		if err != nil {
+			klog.V(1).Infof("callback.Transcript failed. Err: %v\n", err)
+		}
+		i.options.Source.Unmute()
+	}
+
+	i.options.TranscribeOptions.UtteranceEnded(transcript.UtteranceID, transcript.Channel)
 
 	// clear for new sentence
 	i.sb.Reset()
+	i.words = nil
+	i.confidenceSum = 0
+	i.fragments = 0
+	i.utteranceID = ""
+}
 
-	return nil
+// partial delivers an interim result for the current utterance, if anyone is listening
+func (i *Insights) partial(text string, mr *api.MessageResponse) {
+	callback := i.options.TranscribeOptions.GetPartialCallback()
+	if callback == nil {
+		return
+	}
+
+	transcript := &interfaces.Transcript{
+		Text:        text,
+		Confidence:  mr.Channel.Alternatives[0].Confidence,
+		Language:    i.options.Language,
+		IsFinal:     false,
+		UtteranceID: i.utteranceID,
+		Backend:     interfaces.DEEPGRAM_TRANSCRIBER,
+		Timestamp:   time.Now(),
+	}
+	if len(mr.ChannelIndex) > 0 {
+		transcript.Channel = mr.ChannelIndex[0]
+	}
+
+	err := callback.PartialTranscript(transcript)
+	if err != nil {
+		klog.V(1).Infof("callback.PartialTranscript failed. Err: %v\n", err)
+	}
+}
+
+func convertWords(words []api.Word) []interfaces.Word {
+	converted := make([]interfaces.Word, 0, len(words))
+	for _, w := range words {
+		converted = append(converted, interfaces.Word{
+			Word:       w.Word,
+			Start:      time.Duration(w.Start * float64(time.Second)),
+			End:        time.Duration(w.End * float64(time.Second)),
+			Confidence: w.Confidence,
+		})
+	}
+	return converted
 }
 
 func (i *Insights) Metadata(md *api.MetadataResponse) error {
@@ -0,0 +1,30 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package failover
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultFailoverAfter how long a backend can be reconnecting before switching
+	DefaultFailoverAfter = 10 * time.Second
+
+	// DefaultMaxFailures connection losses within DefaultFailureWindow before switching
+	DefaultMaxFailures   = 3
+	DefaultFailureWindow = time.Minute
+
+	// DefaultSwitchBackInterval how often the primary is retried when SwitchBack is set
+	DefaultSwitchBackInterval = time.Minute
+)
+
+var (
+	// ErrNoBackends no transcriber backends were given
+	ErrNoBackends = errors.New("no transcriber backends configured")
+
+	// ErrAllBackendsFailed none of the transcriber backends could be started
+	ErrAllBackendsFailed = errors.New("all transcriber backends failed")
+)
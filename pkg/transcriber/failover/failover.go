@@ -0,0 +1,398 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package failover
+
+import (
+	"context"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+)
+
+var micInitAlready = false
+
+// New creates a transcriber which fails over between the configured backends. The audio
+// source is shared so switching backends does not interrupt listening.
+func New(ctx context.Context, opts *config.TranscribeOptions, cfg FailoverConfig) (*Transcriber, error) {
+	if len(cfg.Backends) == 0 {
+		return nil, ErrNoBackends
+	}
+	if cfg.FailoverAfter == 0 {
+		cfg.FailoverAfter = DefaultFailoverAfter
+	}
+	if cfg.MaxFailures == 0 {
+		cfg.MaxFailures = DefaultMaxFailures
+	}
+	if cfg.FailureWindow == 0 {
+		cfg.FailureWindow = DefaultFailureWindow
+	}
+	if cfg.SwitchBackInterval == 0 {
+		cfg.SwitchBackInterval = DefaultSwitchBackInterval
+	}
+	if opts.InputChannels == 0 {
+		opts.InputChannels = 1
+	}
+	if opts.SamplingRate == 0 {
+		opts.SamplingRate = 16000
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// audio source, fallback to the default mic
+	var source audio.AudioSource
+	ownsMic := false
+	if opts.Source != nil {
+		source = *opts.Source
+	} else {
+		if !micInitAlready {
+			klog.V(4).Infof("Calling microphone.Initialize...")
+			microphone.Initialize()
+			micInitAlready = true
+		}
+
+		mic, err := microphone.New(microphone.AudioConfig{
+			InputChannels: opts.InputChannels,
+			SamplingRate:  float32(opts.SamplingRate),
+			InputDevice:   opts.InputDevice,
+			VoiceActivity: opts.VoiceActivity,
+			PreRoll:       opts.PreRoll,
+			DeviceStatus:  opts.DeviceStatus,
+		})
+		if err != nil {
+			klog.V(1).Infof("microphone.New failed. Err: %v\n", err)
+			microphone.Teardown()
+			return nil, err
+		}
+		source = mic
+		ownsMic = true
+	}
+
+	t := &Transcriber{
+		options: opts,
+		config:  &cfg,
+		source:  source,
+		ownsMic: ownsMic,
+		active:  -1,
+	}
+	t.ctx, t.ctxCancel = context.WithCancel(ctx)
+
+	return t, nil
+}
+
+// Start starts the first backend which works, in order of preference
+func (t *Transcriber) Start() error {
+	t.switchMu.Lock()
+	started := false
+	for i := range t.config.Backends {
+		if t.switchTo(i) == nil {
+			started = true
+			break
+		}
+	}
+	t.switchMu.Unlock()
+
+	if !started {
+		klog.V(1).Infof("failover.Start failed. Err: %v\n", ErrAllBackendsFailed)
+		return ErrAllBackendsFailed
+	}
+
+	err := t.source.Start()
+	if err != nil {
+		klog.V(1).Infof("source.Start failed. Err: %v\n", err)
+		return err
+	}
+
+	// this is a blocking call. if the shared source gives up every backend is deaf,
+	// which is not their fault so no switch is scheduled
+	go func() {
+		err := t.source.Stream(t)
+		if err != nil && t.ctx.Err() == nil {
+			klog.V(1).Infof("source.Stream failed. Err: %v\n", err)
+			t.options.ConnectionLost(t.Backend(), err)
+		}
+	}()
+
+	if t.config.SwitchBack && len(t.config.Backends) > 1 {
+		go t.switchBack()
+	}
+
+	return nil
+}
+
+// Write implements io.Writer by forwarding audio to the active backend
+func (t *Transcriber) Write(buf []byte) (int, error) {
+	t.mu.Lock()
+	r := t.relay
+	t.mu.Unlock()
+
+	if r == nil {
+		return len(buf), nil
+	}
+
+	// a struggling backend must not stop the shared source
+	_, err := r.Write(buf)
+	if err != nil {
+		klog.V(1).Infof("relay.Write failed. Err: %v\n", err)
+	}
+	return len(buf), nil
+}
+
+// switchTo starts the backend at index and retires the current one. The current
+// backend is kept when the new one cannot be started. Must be called with switchMu held.
+func (t *Transcriber) switchTo(index int) error {
+	name := t.config.Backends[index]
+	klog.V(3).Infof("Starting %s transcriber\n", name)
+
+	r := newRelay(t.source)
+
+	opts := *t.options
+	var source audio.AudioSource
+	source = r
+	opts.Source = &source
+
+	var status interfaces.ConnectionStatusCallback
+	status = &watcher{
+		failover: t,
+		index:    index,
+	}
+	opts.ConnectionStatus = &status
+
+	transcriber, err := registry.New(t.ctx, name, &opts)
+	if err != nil {
+		klog.V(1).Infof("registry.New for %s failed. Err: %v\n", name, err)
+		return err
+	}
+
+	err = transcriber.Start()
+	if err != nil {
+		klog.V(1).Infof("transcriber.Start for %s failed. Err: %v\n", name, err)
+		transcriber.Stop()
+		return err
+	}
+
+	t.mu.Lock()
+	old := t.current
+	t.current = transcriber
+	t.relay = r
+	t.active = index
+	t.failures = nil
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mu.Unlock()
+
+	if old != nil {
+		err := old.Stop()
+		if err != nil {
+			klog.V(1).Infof("transcriber.Stop failed. Err: %v\n", err)
+		}
+	}
+
+	klog.V(2).Infof("Transcribing with %s\n", name)
+	return nil
+}
+
+// failover moves on from the backend at index, trying the others in order
+func (t *Transcriber) failover(from int) {
+	t.switchMu.Lock()
+	defer t.switchMu.Unlock()
+
+	t.mu.Lock()
+	active := t.active
+	lostAt := t.lostAt
+	t.mu.Unlock()
+
+	// already moved on, or shutting down
+	if active != from || t.ctx.Err() != nil {
+		return
+	}
+
+	klog.V(1).Infof("Transcriber %s is failing. Switching backends\n", t.config.Backends[from])
+
+	count := len(t.config.Backends)
+	for i := 1; i < count; i++ {
+		next := (from + i) % count
+		if t.switchTo(next) == nil {
+			t.options.BackendSwitched(t.config.Backends[from], t.config.Backends[next], time.Since(lostAt))
+			return
+		}
+	}
+
+	klog.V(1).Infof("No other transcriber could be started. Staying with %s\n", t.config.Backends[from])
+}
+
+// switchBack periodically tries to return to the primary backend
+func (t *Transcriber) switchBack() {
+	ticker := time.NewTicker(t.config.SwitchBackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.switchMu.Lock()
+			t.mu.Lock()
+			active := t.active
+			t.mu.Unlock()
+
+			if active > 0 && t.ctx.Err() == nil {
+				klog.V(3).Infof("Trying to switch back to %s\n", t.config.Backends[0])
+				err := t.switchTo(0)
+				if err != nil {
+					klog.V(3).Infof("%s is still unavailable\n", t.config.Backends[0])
+				} else {
+					t.options.BackendSwitched(t.config.Backends[active], t.config.Backends[0], 0)
+				}
+			}
+			t.switchMu.Unlock()
+		}
+	}
+}
+
+// lost records a connection loss on the backend at index and schedules a switch
+func (t *Transcriber) lost(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index != t.active {
+		return
+	}
+
+	now := time.Now()
+	t.lostAt = now
+
+	recent := t.failures[:0]
+	for _, failure := range t.failures {
+		if now.Sub(failure) < t.config.FailureWindow {
+			recent = append(recent, failure)
+		}
+	}
+	t.failures = append(recent, now)
+
+	// a backend which has given up will not come back on its own
+	if reporter, ok := t.current.(interfaces.ConnectionReporter); ok && reporter.ConnectionState() == interfaces.ConnectionDisconnected {
+		klog.V(2).Infof("%s disconnected for good\n", t.config.Backends[index])
+		go t.failover(index)
+		return
+	}
+
+	if len(t.failures) >= t.config.MaxFailures {
+		klog.V(2).Infof("%d connection failures within %v\n", len(t.failures), t.config.FailureWindow)
+		go t.failover(index)
+		return
+	}
+
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.config.FailoverAfter, func() {
+			t.failover(index)
+		})
+	}
+}
+
+// restored cancels a pending switch when the backend at index comes back
+func (t *Transcriber) restored(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index != t.active {
+		return
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// ConnectionState implements interfaces.ConnectionReporter
+func (t *Transcriber) ConnectionState() interfaces.ConnectionState {
+	t.mu.Lock()
+	current := t.current
+	t.mu.Unlock()
+
+	if current == nil {
+		return interfaces.ConnectionDisconnected
+	}
+	if reporter, ok := current.(interfaces.ConnectionReporter); ok {
+		return reporter.ConnectionState()
+	}
+	return interfaces.ConnectionConnected
+}
+
+// Backend returns the name of the backend currently transcribing
+func (t *Transcriber) Backend() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active < 0 {
+		return ""
+	}
+	return t.config.Backends[t.active]
+}
+
+// Stop stops the active backend and the shared source
+func (t *Transcriber) Stop() error {
+	t.ctxCancel()
+
+	t.switchMu.Lock()
+	defer t.switchMu.Unlock()
+
+	t.mu.Lock()
+	current := t.current
+	t.current = nil
+	t.relay = nil
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mu.Unlock()
+
+	var firstErr error
+	if current != nil {
+		err := current.Stop()
+		if err != nil {
+			klog.V(1).Infof("transcriber.Stop failed. Err: %v\n", err)
+			firstErr = err
+		}
+	}
+
+	err := t.source.Stop()
+	if err != nil {
+		klog.V(1).Infof("source.Stop failed. Err: %v\n", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if t.ownsMic {
+		klog.V(4).Infof("Calling microphone.Teardown...")
+		microphone.Teardown()
+	}
+
+	return firstErr
+}
+
+// ConnectionLost implements interfaces.ConnectionStatusCallback
+func (w *watcher) ConnectionLost(backend string, err error) error {
+	w.failover.options.ConnectionLost(backend, err)
+	w.failover.lost(w.index)
+	return nil
+}
+
+// ConnectionRestored implements interfaces.ConnectionStatusCallback
+func (w *watcher) ConnectionRestored(backend string, downtime time.Duration) error {
+	w.failover.options.ConnectionRestored(backend, downtime)
+	w.failover.restored(w.index)
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package failover
+
+import (
+	"io"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+)
+
+func newRelay(source audio.AudioSource) *relay {
+	return &relay{
+		source: source,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start implements audio.AudioSource. The shared source is started by the failover.
+func (r *relay) Start() error {
+	return nil
+}
+
+// Stream implements audio.AudioSource by handing audio to w until the relay is stopped
+func (r *relay) Stream(w io.Writer) error {
+	r.mu.Lock()
+	r.w = w
+	r.mu.Unlock()
+
+	<-r.done
+
+	r.mu.Lock()
+	r.w = nil
+	r.mu.Unlock()
+	return nil
+}
+
+// Mute implements audio.AudioSource
+func (r *relay) Mute() {
+	r.source.Mute()
+}
+
+// Unmute implements audio.AudioSource
+func (r *relay) Unmute() {
+	r.source.Unmute()
+}
+
+// Stop implements audio.AudioSource. The shared source keeps running.
+func (r *relay) Stop() error {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+	return nil
+}
+
+// Write forwards audio to the backend, dropping it until the backend is streaming
+func (r *relay) Write(buf []byte) (int, error) {
+	r.mu.Lock()
+	w := r.w
+	r.mu.Unlock()
+
+	if w == nil {
+		return len(buf), nil
+	}
+	return w.Write(buf)
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package failover
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// FailoverConfig which backends to use and when to give up on one
+type FailoverConfig struct {
+	// Backends registered transcriber names, most preferred first
+	Backends []string
+
+	// FailoverAfter how long the active backend can be reconnecting before switching
+	FailoverAfter time.Duration
+
+	// MaxFailures connection losses within FailureWindow which trigger a switch
+	MaxFailures   int
+	FailureWindow time.Duration
+
+	// SwitchBack periodically retries the primary while on another backend
+	SwitchBack         bool
+	SwitchBackInterval time.Duration
+}
+
+// Transcriber runs one backend at a time, moving on to the next when it fails
+type Transcriber struct {
+	options *config.TranscribeOptions
+	config  *FailoverConfig
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+
+	source  audio.AudioSource
+	ownsMic bool
+
+	// serializes switching backends
+	switchMu sync.Mutex
+
+	// active backend
+	mu       sync.Mutex
+	active   int
+	current  interfaces.Transcriber
+	relay    *relay
+	failures []time.Time
+	timer    *time.Timer
+	lostAt   time.Time
+}
+
+// relay is the audio source handed to each backend. Only the active backend's relay
+// receives audio from the shared source.
+type relay struct {
+	source audio.AudioSource
+
+	mu       sync.Mutex
+	w        io.Writer
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// watcher tells the failover about connection problems on one backend
+type watcher struct {
+	failover *Transcriber
+	index    int
+}
@@ -0,0 +1,29 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package fake
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultDelay between utterances when the script does not say
+	DefaultDelay = time.Second
+
+	// DefaultConfidence reported on scripted transcripts
+	DefaultConfidence = 1.0
+)
+
+var (
+	// ErrNoScript neither Script nor ScriptFile was given
+	ErrNoScript = errors.New("no script for the fake transcriber")
+
+	// ErrInvalidScript a line in the script file could not be parsed
+	ErrInvalidScript = errors.New("invalid fake transcriber script")
+
+	// ErrAlreadyStarted the script is already being replayed
+	ErrAlreadyStarted = errors.New("fake transcriber already started")
+)
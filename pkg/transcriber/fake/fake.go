@@ -0,0 +1,199 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package fake
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+)
+
+func init() {
+	// the script comes from the environment when selected by name
+	registry.Register(interfaces.FAKE_TRANSCRIBER, func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.Transcriber, error) {
+		cfg := FakeConfig{}
+		if v := os.Getenv("ASSISTANT_FAKE_SCRIPT"); v != "" {
+			klog.V(2).Infof("ASSISTANT_FAKE_SCRIPT found\n")
+			cfg.ScriptFile = v
+		}
+
+		t, err := New(ctx, opts, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// Factory registers a fake with its own script, e.g. in a test:
+//
+//	registry.Register("fake", fake.Factory(fake.FakeConfig{Script: script}))
+func Factory(cfg FakeConfig) registry.Factory {
+	return func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.Transcriber, error) {
+		t, err := New(ctx, opts, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+}
+
+// New creates a fake transcriber which replays the script once started
+func New(ctx context.Context, opts *config.TranscribeOptions, cfg FakeConfig) (*Transcriber, error) {
+	klog.V(6).Infof("fake.New ENTER\n")
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Language == "" {
+		opts.Language = interfaces.DefaultLanguageCode
+	}
+
+	if len(cfg.Script) == 0 {
+		if cfg.ScriptFile == "" {
+			klog.V(1).Infof("fake.New failed. Err: %v\n", ErrNoScript)
+			return nil, ErrNoScript
+		}
+
+		script, err := LoadScript(cfg.ScriptFile)
+		if err != nil {
+			klog.V(1).Infof("LoadScript failed. Err: %v\n", err)
+			return nil, err
+		}
+		cfg.Script = script
+	}
+
+	// a file of only comments would loop over nothing
+	if len(cfg.Script) == 0 {
+		klog.V(1).Infof("fake.New failed. Err: %v\n", ErrNoScript)
+		return nil, ErrNoScript
+	}
+
+	t := &Transcriber{
+		options: opts,
+		config:  &cfg,
+		done:    make(chan struct{}),
+	}
+	t.ctx, t.ctxCancel = context.WithCancel(ctx)
+
+	klog.V(6).Infof("fake.New LEAVE\n")
+	return t, nil
+}
+
+// Start implements interfaces.Transcriber. The script can only be replayed once.
+func (t *Transcriber) Start() error {
+	started := false
+	t.startOnce.Do(func() {
+		klog.V(4).Infof("Replaying %d scripted utterances\n", len(t.config.Script))
+		go t.replay()
+		started = true
+	})
+
+	if !started {
+		klog.V(1).Infof("fake.Start failed. Err: %v\n", ErrAlreadyStarted)
+		return ErrAlreadyStarted
+	}
+	return nil
+}
+
+// Done is closed once the script has been replayed or the transcriber is stopped
+func (t *Transcriber) Done() <-chan struct{} {
+	return t.done
+}
+
+// Stop implements interfaces.Transcriber
+func (t *Transcriber) Stop() error {
+	t.ctxCancel()
+	return nil
+}
+
+// ConnectionState implements interfaces.ConnectionReporter, there is nothing to lose
+func (t *Transcriber) ConnectionState() interfaces.ConnectionState {
+	if t.ctx.Err() != nil {
+		return interfaces.ConnectionDisconnected
+	}
+	return interfaces.ConnectionConnected
+}
+
+func (t *Transcriber) replay() {
+	defer close(t.done)
+
+	for {
+		for _, utterance := range t.config.Script {
+			delay := utterance.Delay
+			if delay == 0 {
+				delay = DefaultDelay
+			}
+
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			t.say(utterance)
+		}
+
+		if !t.config.Loop {
+			klog.V(4).Infof("End of script reached\n")
+			return
+		}
+	}
+}
+
+// say delivers an utterance the way a real backend would: growing partials, the
+// final transcript and then the end of the utterance
+func (t *Transcriber) say(utterance Utterance) {
+	confidence := utterance.Confidence
+	if confidence == 0 {
+		confidence = DefaultConfidence
+	}
+
+	transcript := &interfaces.Transcript{
+		Confidence:  confidence,
+		Language:    t.options.Language,
+		Channel:     utterance.Channel,
+		UtteranceID: config.NewUtteranceID(),
+		Backend:     interfaces.FAKE_TRANSCRIBER,
+	}
+
+	words := strings.Fields(utterance.Text)
+	if callback := t.options.GetPartialCallback(); callback != nil {
+		for i := 1; i < len(words); i++ {
+			partial := *transcript
+			partial.Text = strings.Join(words[:i], " ")
+			partial.Timestamp = time.Now()
+
+			err := callback.PartialTranscript(&partial)
+			if err != nil {
+				klog.V(1).Infof("callback.PartialTranscript failed. Err: %v\n", err)
+			}
+		}
+	}
+
+	transcript.Text = strings.Join(words, " ")
+	transcript.IsFinal = true
+	transcript.Timestamp = time.Now()
+	transcript.Alternatives = []interfaces.Alternative{
+		{Text: transcript.Text, Confidence: confidence},
+	}
+	klog.V(3).Infof("fake transcription: text = %s\n", transcript.Text)
+
+	if callback := t.options.GetTranscriptCallback(); callback != nil {
+		err := callback.Transcript(transcript)
+		if err != nil {
+			klog.V(1).Infof("callback.Transcript failed. Err: %v\n", err)
+		}
+	}
+
+	t.options.UtteranceEnded(transcript.UtteranceID, transcript.Channel)
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package fake
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// LoadScript reads a script file in the format described by FakeConfig.ScriptFile
+func LoadScript(filePath string) ([]Utterance, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		klog.V(1).Infof("os.Open failed. Err: %v\n", err)
+		return nil, err
+	}
+	defer file.Close()
+
+	script := make([]Utterance, 0)
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		utterance := Utterance{
+			Text: text,
+		}
+
+		// a leading duration is the delay
+		fields := strings.SplitN(text, " ", 2)
+		if delay, errParse := time.ParseDuration(fields[0]); errParse == nil {
+			if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+				klog.V(1).Infof("%s:%d has a delay but nothing to say\n", filePath, line)
+				return nil, ErrInvalidScript
+			}
+			utterance.Delay = delay
+			utterance.Text = strings.TrimSpace(fields[1])
+		}
+
+		script = append(script, utterance)
+	}
+	if err := scanner.Err(); err != nil {
+		klog.V(1).Infof("scanner.Scan failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	return script, nil
+}
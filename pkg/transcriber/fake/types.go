@@ -0,0 +1,54 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package fake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+)
+
+// Utterance one scripted line
+type Utterance struct {
+	// Delay how long to wait after the previous utterance, DefaultDelay when zero
+	Delay time.Duration
+	Text  string
+
+	// Confidence reported on the transcript, DefaultConfidence when zero
+	Confidence float64
+	Channel    int
+}
+
+// FakeConfig what the fake transcriber says
+type FakeConfig struct {
+	// Script utterances to replay, in order. Takes precedence over ScriptFile.
+	Script []Utterance
+
+	// ScriptFile one utterance per line, optionally starting with a delay:
+	//
+	//	# comments and blank lines are ignored
+	//	2s hey kitt what time is it
+	//	500ms thanks
+	//	hello there
+	ScriptFile string
+
+	// Loop starts the script over once it is done
+	Loop bool
+}
+
+// Transcriber replays a script of utterances as if someone had said them. It needs
+// no audio hardware, credentials or network.
+type Transcriber struct {
+	options *config.TranscribeOptions
+	config  *FakeConfig
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+
+	startOnce sync.Once
+	done      chan struct{}
+}
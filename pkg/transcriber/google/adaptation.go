@@ -0,0 +1,71 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package google
+
+import (
+	speechpb "cloud.google.com/go/speech/apiv1/speechpb"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+)
+
+// DefaultAdaptation is used when TranscribeOptions.Adaptation is nil
+var DefaultAdaptation = config.SpeechAdaptation{
+	Phrases: []config.Phrase{
+		{Value: "${hello} ${gpt}", Boost: 16},
+		{Value: "${gpt}", Boost: 16},
+		{Value: "Hey ${gpt}", Boost: 16},
+		{Value: "Kitt", Boost: 16},
+		{Value: "Kit-t", Boost: 16},
+		{Value: "Kit", Boost: 16},
+		{Value: "${action} a task ${named}", Boost: 16},
+		{Value: "${action} the task ${named}", Boost: 16},
+		{Value: "${action} a job ${named}", Boost: 16},
+		{Value: "${action} the job ${named}", Boost: 16},
+		{Value: "task", Boost: 16},
+		{Value: "job", Boost: 16},
+		{Value: "${action}", Boost: 16},
+		{Value: "${named}", Boost: 16},
+	},
+	Classes: []config.PhraseClass{
+		{Name: "hello", Values: []string{"Hi", "Hello", "Hey"}},
+		{Name: "gpt", Values: []string{"Kit", "KITT", "GPT"}},
+		{Name: "action", Values: []string{"create", "activate", "resume"}},
+		{Name: "named", Values: []string{"name", "named", "called"}},
+	},
+}
+
+// toSpeechAdaptation translates the backend neutral adaptation. Google understands
+// ${class} references natively so they are passed through as custom classes.
+func toSpeechAdaptation(adaptation *config.SpeechAdaptation) *speechpb.SpeechAdaptation {
+	if adaptation == nil {
+		adaptation = &DefaultAdaptation
+	}
+	if len(adaptation.Phrases) == 0 {
+		return nil
+	}
+
+	phraseSet := &speechpb.PhraseSet{}
+	for _, phrase := range adaptation.Phrases {
+		phraseSet.Phrases = append(phraseSet.Phrases, &speechpb.PhraseSet_Phrase{
+			Value: phrase.Value,
+			Boost: phrase.Boost,
+		})
+	}
+
+	result := &speechpb.SpeechAdaptation{
+		PhraseSets: []*speechpb.PhraseSet{phraseSet},
+	}
+	for _, class := range adaptation.Classes {
+		customClass := &speechpb.CustomClass{
+			CustomClassId: class.Name,
+		}
+		for _, value := range class.Values {
+			customClass.Items = append(customClass.Items, &speechpb.CustomClass_ClassItem{Value: value})
+		}
+		result.CustomClasses = append(result.CustomClasses, customClass)
+	}
+
+	return result
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package google
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	speechtotext "cloud.google.com/go/speech/apiv1"
+	speechpb "cloud.google.com/go/speech/apiv1/speechpb"
+
+	file "github.com/dvonthenen/open-virtual-assistant/pkg/audio/file"
+	"github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+const (
+	// DefaultSyncLimit recordings up to this long use Recognize, longer ones
+	// LongRunningRecognize. Either way inline audio is capped at DefaultMaxInlineBytes,
+	// so only recordings in Cloud Storage can be much longer.
+	DefaultSyncLimit = time.Minute
+
+	// DefaultMaxInlineBytes google rejects inline audio over 10 MB
+	DefaultMaxInlineBytes = 10 * 1024 * 1024
+
+	// DefaultInlineSamplingRate recordings which are too big to send inline are
+	// downmixed to mono at this rate, which fits a little over five minutes
+	DefaultInlineSamplingRate = 16000
+
+	// DefaultFileModel suits recordings better than the live command_and_search model
+	DefaultFileModel = "default"
+)
+
+var (
+	// ErrFileTooLarge the recording is too long to send inline, upload it to Cloud
+	// Storage and pass the gs:// URI instead
+	ErrFileTooLarge = errors.New("recording too large to send inline, use a gs:// URI")
+)
+
+// FileTranscribe transcribes recordings using Recognize and LongRunningRecognize
+type FileTranscribe struct {
+	options *config.TranscribeOptions
+
+	googleClient *speechtotext.Client
+}
+
+// NewFile creates a file transcriber using the same language and phrases as the live one
+func NewFile(ctx context.Context, opts *config.TranscribeOptions) (*FileTranscribe, error) {
+	klog.V(6).Infof("transcribe.NewFile ENTER\n")
+
+	if opts.Language == "" {
+		opts.Language = DefaultLanguage
+	}
+
+	if v := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); v == "" {
+		klog.Error("GOOGLE_APPLICATION_CREDENTIALS not found")
+		klog.V(6).Infof("transcribe.NewFile LEAVE\n")
+		return nil, ErrInvalidInput
+	}
+
+	googleClient, err := speechtotext.NewClient(ctx)
+	if err != nil {
+		klog.V(1).Infof("speechtotext.NewClient failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	klog.V(6).Infof("transcribe.NewFile LEAVE\n")
+	return &FileTranscribe{
+		options:      opts,
+		googleClient: googleClient,
+	}, nil
+}
+
+// TranscribeFile implements interfaces.FileTranscriber. Local files are sent inline,
+// downmixed to DefaultInlineSamplingRate mono if they would not fit otherwise. MP3
+// files are decoded locally since Google only takes them in its beta API. Recordings
+// too long to send inline need uploading to Cloud Storage and passing as gs://bucket/file.
+func (f *FileTranscribe) TranscribeFile(ctx context.Context, filePath string) (*interfaces.FileTranscript, error) {
+	klog.V(6).Infof("transcribe.TranscribeFile ENTER\n")
+	defer klog.V(6).Infof("transcribe.TranscribeFile LEAVE\n")
+
+	recognitionConfig := &speechpb.RecognitionConfig{
+		Model:                      DefaultFileModel,
+		Adaptation:                 toSpeechAdaptation(f.options.Adaptation),
+		EnableWordTimeOffsets:      true,
+		EnableWordConfidence:       true,
+		EnableAutomaticPunctuation: true,
+		LanguageCode:               f.options.Language,
+		AlternativeLanguageCodes:   f.options.AlternativeLanguages,
+	}
+
+	// google reads the format from the WAV or FLAC header in the bucket
+	if strings.HasPrefix(filePath, "gs://") {
+		results, err := f.longRunningRecognize(ctx, recognitionConfig, &speechpb.RecognitionAudio{
+			AudioSource: &speechpb.RecognitionAudio_Uri{Uri: filePath},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var duration time.Duration
+		if len(results) > 0 {
+			duration = results[len(results)-1].ResultEndTime.AsDuration()
+		}
+		return f.newFileTranscript(results, duration), nil
+	}
+
+	pcm, err := file.Decode(file.FileConfig{
+		FilePath:      filePath,
+		InputChannels: f.options.InputChannels,
+		SamplingRate:  f.options.SamplingRate,
+	})
+	if err != nil {
+		klog.V(1).Infof("file.Decode failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	if len(pcm.Data) > DefaultMaxInlineBytes && (pcm.InputChannels > 1 || pcm.SamplingRate > DefaultInlineSamplingRate) {
+		klog.V(3).Infof("%d bytes is too much to send inline. Downmixing to %d Hz mono\n", len(pcm.Data), DefaultInlineSamplingRate)
+		pcm = pcm.Mono(DefaultInlineSamplingRate)
+	}
+	if len(pcm.Data) > DefaultMaxInlineBytes {
+		klog.V(1).Infof("%s is %v long, too long to send inline. Upload it to Cloud Storage and pass the gs:// URI instead\n", filePath, pcm.Duration())
+		return nil, ErrFileTooLarge
+	}
+
+	recognitionConfig.Encoding = speechpb.RecognitionConfig_LINEAR16
+	recognitionConfig.SampleRateHertz = int32(pcm.SamplingRate)
+	recognitionConfig.AudioChannelCount = int32(pcm.InputChannels)
+	recognitionConfig.EnableSeparateRecognitionPerChannel = pcm.InputChannels > 1
+
+	audio := &speechpb.RecognitionAudio{
+		AudioSource: &speechpb.RecognitionAudio_Content{Content: pcm.Data},
+	}
+
+	var results []*speechpb.SpeechRecognitionResult
+	if pcm.Duration() <= DefaultSyncLimit {
+		klog.V(4).Infof("Calling Recognize. Duration: %v\n", pcm.Duration())
+
+		resp, err := f.googleClient.Recognize(ctx, &speechpb.RecognizeRequest{
+			Config: recognitionConfig,
+			Audio:  audio,
+		})
+		if err != nil {
+			klog.V(1).Infof("googleClient.Recognize failed. Err: %v\n", err)
+			return nil, err
+		}
+		results = resp.Results
+	} else {
+		results, err = f.longRunningRecognize(ctx, recognitionConfig, audio)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := f.newFileTranscript(results, pcm.Duration())
+	klog.V(3).Infof("Google file transcription: segments = %d, duration = %v\n", len(result.Segments), result.Duration)
+
+	return result, nil
+}
+
+// longRunningRecognize transcribes audio over DefaultSyncLimit, blocking until google is done
+func (f *FileTranscribe) longRunningRecognize(ctx context.Context, recognitionConfig *speechpb.RecognitionConfig, audio *speechpb.RecognitionAudio) ([]*speechpb.SpeechRecognitionResult, error) {
+	klog.V(4).Infof("Calling LongRunningRecognize\n")
+
+	op, err := f.googleClient.LongRunningRecognize(ctx, &speechpb.LongRunningRecognizeRequest{
+		Config: recognitionConfig,
+		Audio:  audio,
+	})
+	if err != nil {
+		klog.V(1).Infof("googleClient.LongRunningRecognize failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	resp, err := op.Wait(ctx)
+	if err != nil {
+		klog.V(1).Infof("op.Wait failed. Err: %v\n", err)
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Close implements interfaces.FileTranscriber
+func (f *FileTranscribe) Close() error {
+	return f.googleClient.Close()
+}
+
+// newFileTranscript turns the results into segments. Each result starts where the
+// previous one on the same channel ended.
+func (f *FileTranscribe) newFileTranscript(results []*speechpb.SpeechRecognitionResult, duration time.Duration) *interfaces.FileTranscript {
+	result := &interfaces.FileTranscript{
+		Language: f.options.Language,
+		Duration: duration,
+		Backend:  interfaces.GOOGLE_TRANSCRIBER,
+	}
+
+	pipeline := f.options.GetPostProcess()
+	lines := make([]string, 0, len(results))
+	ended := make(map[int32]time.Duration)
+
+	for _, r := range results {
+		start := ended[r.ChannelTag]
+		end := r.ResultEndTime.AsDuration()
+		ended[r.ChannelTag] = end
+
+		if len(r.Alternatives) == 0 {
+			continue
+		}
+
+		language := f.options.Language
+		if r.LanguageCode != "" {
+			language = r.LanguageCode
+			result.Language = r.LanguageCode
+		}
+
+		transcript := &interfaces.Transcript{
+			Text:         strings.TrimSpace(r.Alternatives[0].Transcript),
+			Alternatives: convertAlternatives(r.Alternatives),
+			Language:     language,
+			Channel:      channelIndex(r.ChannelTag),
+			IsFinal:      true,
+			UtteranceID:  config.NewUtteranceID(),
+			Backend:      interfaces.GOOGLE_TRANSCRIBER,
+			Timestamp:    time.Now(),
+		}
+		transcript.Confidence = transcript.Alternatives[0].Confidence
+		transcript.Words = transcript.Alternatives[0].Words
+		if len(transcript.Words) > 0 {
+			start = transcript.Words[0].Start
+		}
+
+		transcript = pipeline.Apply(transcript)
+		if transcript.Text == "" {
+			continue
+		}
+
+		result.Segments = append(result.Segments, interfaces.Segment{
+			Transcript: *transcript,
+			Start:      start,
+			End:        end,
+		})
+		lines = append(lines, transcript.Text)
+	}
+	result.Text = strings.Join(lines, "\n")
+
+	return result
+}
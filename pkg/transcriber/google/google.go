@@ -7,45 +7,95 @@ package google
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	klog "k8s.io/klog/v2"
 
 	speechtotext "cloud.google.com/go/speech/apiv1"
 	speechpb "cloud.google.com/go/speech/apiv1/speechpb"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
 	microphone "github.com/dvonthenen/open-virtual-assistant/pkg/microphone"
 	"github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	registry "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/registry"
+	segmenter "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/segmenter"
 )
 
 const (
-	DefaultLanguage = "en-US"
+	DefaultLanguage = interfaces.DefaultLanguageCode
+
+	// DefaultStreamLimit rotates streams ahead of Google's five minute limit
+	DefaultStreamLimit = 290 * time.Second
+
+	// DefaultReconnectBackoff is the first wait between reconnect attempts, doubling
+	// up to DefaultMaxReconnectBackoff
+	DefaultReconnectBackoff    = 250 * time.Millisecond
+	DefaultMaxReconnectBackoff = 10 * time.Second
+
+	// DefaultMaxPending is how much unfinalized audio is kept for replay after a failure
+	DefaultMaxPending = 15 * time.Second
+
+	// DefaultKeepAliveInterval Google ends streams which go about ten seconds without
+	// audio, e.g. while the mic is gated on voice activity, so DefaultKeepAliveSilence
+	// is sent after this long without any
+	DefaultKeepAliveInterval = 4 * time.Second
+	DefaultKeepAliveSilence  = 100 * time.Millisecond
 )
 
 var (
 	// ErrInvalidInput required input was not found
 	ErrInvalidInput = errors.New("required input was not found")
+
+	// ErrStreamFailed the stream failed in a way reconnecting cannot fix, e.g. bad
+	// credentials or an invalid config
+	ErrStreamFailed = errors.New("google stream failed permanently")
 )
 
 type Transcribe struct {
 	options *config.TranscribeOptions
 
 	googleClient      *speechtotext.Client
-	client            speechpb.Speech_StreamingRecognizeClient
 	googleCredentials string
 
+	// current stream, replaced on rotation and reconnect
+	mu              sync.Mutex
+	stream          *recognizeStream
+	state           interfaces.ConnectionState
+	failure         error
+	maxPendingBytes int
+	lastWrite       time.Time
+
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	mic *microphone.Microphone
+	source    audio.AudioSource
+	ownsMic   bool
+	segmenter *segmenter.Segmenter
 }
 
 var micInitAlready = false
 
+func init() {
+	registry.Register(interfaces.GOOGLE_TRANSCRIBER, func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.Transcriber, error) {
+		t, err := New(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+	registry.RegisterFile(interfaces.GOOGLE_TRANSCRIBER, func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.FileTranscriber, error) {
+		t, err := NewFile(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
 func New(ctx context.Context, opts *config.TranscribeOptions) (*Transcribe, error) {
 	if opts.InputChannels == 0 {
 		opts.InputChannels = 1
@@ -53,25 +103,49 @@ func New(ctx context.Context, opts *config.TranscribeOptions) (*Transcribe, erro
 	if opts.SamplingRate == 0 {
 		opts.SamplingRate = 16000
 	}
+	if opts.Language == "" {
+		opts.Language = DefaultLanguage
+	}
 
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	// mic stuf
-	if !micInitAlready {
-		klog.V(4).Infof("Calling microphone.Initialize...")
-		microphone.Initialize()
-		micInitAlready = true
+	// audio source, fallback to the default mic
+	var source audio.AudioSource
+	ownsMic := false
+	if opts.Source != nil {
+		klog.V(4).Infof("Using the provided audio source")
+		source = *opts.Source
+	} else {
+		if !micInitAlready {
+			klog.V(4).Infof("Calling microphone.Initialize...")
+			microphone.Initialize()
+			micInitAlready = true
+		}
+
+		mic, err := microphone.New(microphone.AudioConfig{
+			InputChannels: opts.InputChannels,
+			SamplingRate:  float32(opts.SamplingRate),
+			InputDevice:   opts.InputDevice,
+			VoiceActivity: opts.VoiceActivity,
+			PreRoll:       opts.PreRoll,
+			DeviceStatus:  opts.DeviceStatus,
+		})
+		if err != nil {
+			klog.V(1).Infof("New failed. Err: %v\n", err)
+			return nil, err
+		}
+		source = mic
+		ownsMic = true
 	}
 
-	mic, err := microphone.New(microphone.AudioConfig{
-		InputChannels: opts.InputChannels,
-		SamplingRate:  float32(opts.SamplingRate),
-	})
-	if err != nil {
-		klog.V(1).Infof("New failed. Err: %v\n", err)
-		return nil, err
+	// google ends utterances on its own terms, so segmentation is done locally
+	var seg *segmenter.Segmenter
+	if segmenter.Needed(opts, false) {
+		klog.V(4).Infof("Using local segmentation\n")
+		seg = segmenter.New(opts, source)
+		opts = seg.Options()
 	}
 
 	// google speech to text
@@ -91,19 +165,15 @@ func New(ctx context.Context, opts *config.TranscribeOptions) (*Transcribe, erro
 		return nil, err
 	}
 
-	client, err := googleClient.StreamingRecognize(ctx)
-	if err != nil {
-		klog.V(1).Infof("client.StreamingRecognize failed. Err: %v\n", err)
-		return nil, err
-	}
-
 	t := &Transcribe{
 		options:           opts,
 		ctx:               ctx,
 		googleClient:      googleClient,
-		client:            client,
 		googleCredentials: googleCredentials,
-		mic:               mic,
+		source:            source,
+		ownsMic:           ownsMic,
+		segmenter:         seg,
+		maxPendingBytes:   int(DefaultMaxPending.Seconds()) * opts.SamplingRate * opts.InputChannels * 2,
 	}
 	t.ctx, t.ctxCancel = context.WithCancel(ctx)
 
@@ -117,23 +187,36 @@ func (t *Transcribe) Start() error {
 	err := t.connect()
 	if err != nil {
 		err := errors.New("client.Connect failed")
-		klog.V(1).Infof("transcribe.Start failed", err)
+		klog.V(1).Infof("transcribe.Start failed. Err: %v\n", err)
 		return err
 	}
 	klog.V(4).Infof("client.Connect succeeded")
 
-	// start the mic
-	err = t.mic.Start()
+	// start the audio source
+	err = t.source.Start()
 	if err != nil {
-		klog.V(1).Infof("mic.Start failed. Err: %v\n", err)
+		klog.V(1).Infof("source.Start failed. Err: %v\n", err)
 		klog.V(6).Infof("transcribe.Start LEAVE\n")
 		return err
 	}
-	klog.V(4).Infof("mic.Start succeeded")
+	klog.V(4).Infof("source.Start succeeded")
+
+	// tee to the recorder
+	var w io.Writer
+	w = t
+	if t.options.Recorder != nil {
+		w = io.MultiWriter(t, t.options.Recorder)
+	}
 
-	// this is a blocking call
+	// this is a blocking call. a source that gives up, like a failed splitter channel,
+	// leaves this transcriber deaf so the owner is told to restart it. ErrStreamFailed
+	// was already reported by listen.
 	go func() {
-		t.mic.Stream(t)
+		err := t.source.Stream(w)
+		if err != nil && t.ctx.Err() == nil && !errors.Is(err, ErrStreamFailed) {
+			klog.V(1).Infof("source.Stream failed. Err: %v\n", err)
+			t.options.ConnectionLost(interfaces.GOOGLE_TRANSCRIBER, err)
+		}
 	}()
 
 	klog.V(4).Infof("transcribe.Start Succeeded\n")
@@ -144,162 +227,165 @@ func (t *Transcribe) Start() error {
 func (t *Transcribe) connect() error {
 	klog.V(5).Infof("calling Transcribe.connect")
 
-	config := &speechpb.RecognitionConfig{
-		Model: "command_and_search",
-		Adaptation: &speechpb.SpeechAdaptation{
-			PhraseSets: []*speechpb.PhraseSet{
-				{
-					Phrases: []*speechpb.PhraseSet_Phrase{
-						{Value: "${hello} ${gpt}"},
-						{Value: "${gpt}"},
-						{Value: "Hey ${gpt}"},
-						{Value: "Kitt"},
-						{Value: "Kit-t"},
-						{Value: "Kit"},
-						{Value: "${action} a task ${named}"},
-						{Value: "${action} the task ${named}"},
-						{Value: "${action} a job ${named}"},
-						{Value: "${action} the job ${named}"},
-						{Value: "task"},
-						{Value: "job"},
-						{Value: "${action}"},
-						{Value: "${named}"},
-					},
-					Boost: 16,
-				},
-			},
-			CustomClasses: []*speechpb.CustomClass{
-				{
-					CustomClassId: "hello",
-					Items: []*speechpb.CustomClass_ClassItem{
-						{Value: "Hi"},
-						{Value: "Hello"},
-						{Value: "Hey"},
-					},
-				},
-				{
-					CustomClassId: "gpt",
-					Items: []*speechpb.CustomClass_ClassItem{
-						{Value: "Kit"},
-						{Value: "KITT"},
-						{Value: "GPT"},
-					},
-				},
-				{
-					CustomClassId: "action",
-					Items: []*speechpb.CustomClass_ClassItem{
-						{Value: "create"},
-						{Value: "activate"},
-						{Value: "resume"},
-					},
-				},
-				{
-					CustomClassId: "named",
-					Items: []*speechpb.CustomClass_ClassItem{
-						{Value: "name"},
-						{Value: "named"},
-						{Value: "called"},
-					},
-				},
-			},
-		},
-		UseEnhanced:       true,
-		Encoding:          speechpb.RecognitionConfig_LINEAR16,
-		SampleRateHertz:   int32(t.options.SamplingRate),
-		AudioChannelCount: int32(t.options.InputChannels),
-		LanguageCode:      DefaultLanguage,
+	// google only picks between the languages it is given
+	if t.options.DetectLanguage && len(t.options.AlternativeLanguages) == 0 {
+		klog.V(2).Infof("DetectLanguage requires AlternativeLanguages for Google. Using %s only\n", t.options.Language)
 	}
 
-	if err := t.client.Send(&speechpb.StreamingRecognizeRequest{
-		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
-			StreamingConfig: &speechpb.StreamingRecognitionConfig{
-				InterimResults: true,
-				Config:         config,
-			},
-		},
-	}); err != nil {
-		klog.V(1).Infof("client.Send failed. Err: %v\n", err)
+	stream, err := t.openStream()
+	if err != nil {
+		klog.V(1).Infof("openStream failed. Err: %v\n", err)
 		return err
 	}
 
+	t.mu.Lock()
+	t.stream = stream
+	t.state = interfaces.ConnectionConnected
+	t.lastWrite = time.Now()
+	t.mu.Unlock()
+
 	// kick off threads
-	go t.listen()
+	go t.listen(stream)
+	go t.keepAlive()
 
 	klog.V(6).Infof("new speech stream created successfully")
 	return nil
 }
 
-func (t *Transcribe) listen() {
-	ticker := time.NewTicker(500 * time.Millisecond)
+func (t *Transcribe) recognitionConfig() *speechpb.RecognitionConfig {
+	return &speechpb.RecognitionConfig{
+		Model:                    "command_and_search",
+		Adaptation:               toSpeechAdaptation(t.options.Adaptation),
+		UseEnhanced:              true,
+		EnableWordTimeOffsets:    true,
+		EnableWordConfidence:     true,
+		Encoding:                 speechpb.RecognitionConfig_LINEAR16,
+		SampleRateHertz:          int32(t.options.SamplingRate),
+		AudioChannelCount:        int32(t.options.InputChannels),
+		LanguageCode:             t.options.Language,
+		AlternativeLanguageCodes: t.options.AlternativeLanguages,
+	}
+}
+
+// ConnectionState implements interfaces.ConnectionReporter
+func (t *Transcribe) ConnectionState() interfaces.ConnectionState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// newTranscript converts a streaming result into a transcript event. The language
+// is only reported when alternative languages are configured, otherwise it is the
+// one we asked for.
+// channelIndex google numbers channels from 1 when recognizing them separately and
+// reports 0 otherwise, the other backends number them from 0
+func channelIndex(tag int32) int {
+	if tag > 0 {
+		return int(tag) - 1
+	}
+	return 0
+}
+
+func newTranscript(text, language string, result *speechpb.StreamingRecognitionResult) *interfaces.Transcript {
+	if result.LanguageCode != "" {
+		language = result.LanguageCode
+	}
+
+	transcript := &interfaces.Transcript{
+		Text:      text,
+		Language:  language,
+		Channel:   channelIndex(result.ChannelTag),
+		IsFinal:   result.IsFinal,
+		Backend:   interfaces.GOOGLE_TRANSCRIBER,
+		Timestamp: time.Now(),
+	}
+
+	transcript.Alternatives = convertAlternatives(result.Alternatives)
+	if len(transcript.Alternatives) > 0 {
+		transcript.Confidence = transcript.Alternatives[0].Confidence
+		transcript.Words = transcript.Alternatives[0].Words
+	}
+
+	return transcript
+}
+
+func convertAlternatives(alternatives []*speechpb.SpeechRecognitionAlternative) []interfaces.Alternative {
+	converted := make([]interfaces.Alternative, 0, len(alternatives))
+	for _, alt := range alternatives {
+		words := make([]interfaces.Word, 0, len(alt.Words))
+		for _, w := range alt.Words {
+			words = append(words, interfaces.Word{
+				Word:       w.Word,
+				Start:      w.StartTime.AsDuration(),
+				End:        w.EndTime.AsDuration(),
+				Confidence: float64(w.Confidence),
+			})
+		}
+		converted = append(converted, interfaces.Alternative{
+			Text:       alt.Transcript,
+			Confidence: float64(alt.Confidence),
+			Words:      words,
+		})
+	}
+	return converted
+}
+
+// Write performs the lower level write operation. Audio is held while a failed
+// stream is being replaced so it can be replayed on the new one. Once the stream
+// has failed permanently, Write returns ErrStreamFailed.
+func (t *Transcribe) Write(buf []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failure != nil {
+		return 0, ErrStreamFailed
+	}
+
+	return t.writeLocked(buf)
+}
+
+// keepAlive stops Google from ending the stream while the source sends nothing.
+// The silence goes through writeLocked so offsets into the stream stay in step.
+func (t *Transcribe) keepAlive() {
+	ticker := time.NewTicker(DefaultKeepAliveInterval)
 	defer ticker.Stop()
+
+	silence := make([]byte, int64(DefaultKeepAliveSilence)*int64(t.options.SamplingRate*t.options.InputChannels*2)/int64(time.Second))
+
 	for {
 		select {
 		case <-t.ctx.Done():
 			return
 		case <-ticker.C:
-			for {
-				resp, err := t.client.Recv()
-				if err != nil {
-					klog.V(1).Infof("client.Recv failed. Err: %v\n", err)
-
-					if status, ok := status.FromError(err); ok {
-						if status.Code() == codes.OutOfRange {
-							klog.V(1).Infof("client recognize out of range")
-							break
-						} else if status.Code() == codes.Canceled {
-							klog.V(1).Infof("client recognize canceled")
-							break
-						}
-					}
-				}
-
-				if resp.Error != nil {
-					klog.V(1).Infof("client.Recv failed. resp.Error: ", resp.Error)
-					break
-				}
-
-				// Read the whole transcription and put inside one string
-				// We don't need to process each part individually (atm?)
-				var sb strings.Builder
-				for _, result := range resp.Results {
-					alt := result.Alternatives[0]
-					text := alt.Transcript
-
-					// apepend to string builder
-					sb.WriteString(text)
-
-					if !result.IsFinal {
-						// klog.V(4).Infof("isFinal = FALSE")
-						continue
-					}
-
-					// Debug... what is being said word for word
-					sentence := sb.String()
-					klog.V(3).Infof("google transcription: text=%s final=%t\n", sentence, result.IsFinal)
-
-					if t.options.Callback != nil {
-						t.mic.Mute()
-						(*t.options.Callback).Response(sentence)
-						t.mic.Unmute()
-					} else {
-						klog.V(2).Infof("stream.Recv() text=%s final=%t\n", sentence, result.IsFinal)
-					}
-					sb.Reset()
-				}
+			t.mu.Lock()
+			if t.state == interfaces.ConnectionConnected && time.Since(t.lastWrite) >= DefaultKeepAliveInterval {
+				klog.V(5).Infof("Sending Google keepalive silence\n")
+				t.writeLocked(silence)
 			}
+			t.mu.Unlock()
 		}
 	}
 }
 
-// Write performs the lower level write operation
-func (t *Transcribe) Write(buf []byte) (int, error) {
-	if err := t.client.Send(&speechpb.StreamingRecognizeRequest{
-		StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
-			AudioContent: buf,
-		},
-	}); err != nil {
+// writeLocked sends or holds audio for the current stream. Must be called with t.mu held.
+func (t *Transcribe) writeLocked(buf []byte) (int, error) {
+	capturedAt := time.Now()
+	t.lastWrite = capturedAt
+
+	if t.stream.failed {
+		t.stream.remember(buf, capturedAt, t.maxPendingBytes)
+		return len(buf), nil
+	}
+
+	if time.Since(t.stream.started) >= DefaultStreamLimit {
+		t.rotateLocked()
+	}
+
+	t.stream.remember(buf, capturedAt, t.maxPendingBytes)
+	if err := t.stream.send(buf, capturedAt); err != nil {
+		// the receiving side finds out why and reconnects
 		klog.V(1).Infof("stream.Send failed. Err: %v\n", err)
-		return 0, err
+		t.stream.failed = true
 	}
 
 	return len(buf), nil
@@ -308,19 +394,36 @@ func (t *Transcribe) Write(buf []byte) (int, error) {
 func (t *Transcribe) Stop() error {
 	klog.V(5).Infof("calling Transcribe.Stop")
 
+	// stop reconnecting and close the stream
+	t.ctxCancel()
+
+	t.mu.Lock()
+	t.state = interfaces.ConnectionDisconnected
+	if t.stream != nil {
+		t.stream.client.CloseSend()
+	}
+	t.mu.Unlock()
+
 	// google client
 	t.googleClient.Close()
 
-	// close mic stream
-	err := t.mic.Stop()
+	// deliver what has been heard so far
+	if t.segmenter != nil {
+		t.segmenter.Close()
+	}
+
+	// close audio source
+	err := t.source.Stop()
 	if err != nil {
-		klog.V(1).Infof("mic.Stop failed. Err: %v\n", err)
+		klog.V(1).Infof("source.Stop failed. Err: %v\n", err)
 	}
-	klog.V(4).Infof("mic.Stop succeeded")
+	klog.V(4).Infof("source.Stop succeeded")
 
 	// microphone teardown
-	klog.V(4).Infof("Calling microphone.Teardown...")
-	microphone.Teardown()
+	if t.ownsMic {
+		klog.V(4).Infof("Calling microphone.Teardown...")
+		microphone.Teardown()
+	}
 
 	return nil
 }
@@ -0,0 +1,219 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package google
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	speechpb "cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// streamError how a stream came to an end
+type streamError int
+
+const (
+	// streamShutdown the transcriber is being stopped
+	streamShutdown streamError = iota
+	// streamClosed google finished the stream, e.g. after a rotation
+	streamClosed
+	// streamRecoverable a new stream is likely to succeed
+	streamRecoverable
+	// streamPermanent retrying will not help, e.g. bad credentials or config
+	streamPermanent
+)
+
+// listen receives results for stream until it ends. Recv blocks until google has
+// something to say, so results are delivered as soon as they arrive.
+func (t *Transcribe) listen(stream *recognizeStream) {
+	klog.V(6).Infof("google.listen ENTER\n")
+	defer klog.V(6).Infof("google.listen LEAVE\n")
+
+	for {
+		resp, err := stream.client.Recv()
+		if err == nil && resp.Error != nil {
+			err = status.ErrorProto(resp.Error)
+		}
+		if err != nil {
+			t.streamEnded(stream, err)
+			return
+		}
+
+		t.handleResponse(stream, resp)
+	}
+}
+
+// classify decides what the error ending a stream means
+func (t *Transcribe) classify(err error) streamError {
+	if t.ctx.Err() != nil {
+		return streamShutdown
+	}
+	if errors.Is(err, io.EOF) {
+		return streamClosed
+	}
+
+	switch status.Code(err) {
+	case codes.OutOfRange, codes.Canceled, codes.Unavailable, codes.DeadlineExceeded,
+		codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return streamRecoverable
+	}
+	return streamPermanent
+}
+
+// streamEnded reconnects unless the failure is permanent or we are shutting down. A
+// permanent failure disconnects the transcriber and is reported as a lost connection.
+func (t *Transcribe) streamEnded(stream *recognizeStream, err error) {
+	kind := t.classify(err)
+	if kind == streamShutdown {
+		klog.V(4).Infof("google stream stopped\n")
+		return
+	}
+
+	t.mu.Lock()
+	stream.failed = true
+	current := t.stream == stream
+	t.mu.Unlock()
+
+	// a rotated out stream ending while draining is expected, and only loses what it
+	// had not finalized if it failed
+	if !current {
+		klog.V(4).Infof("rotated google stream ended. Err: %v\n", err)
+		return
+	}
+
+	switch kind {
+	case streamPermanent:
+		// nothing will be heard until we are recreated, so say so instead of going deaf
+		klog.V(1).Infof("Google stream failed permanently. Err: %v\n", err)
+
+		t.mu.Lock()
+		t.state = interfaces.ConnectionDisconnected
+		t.failure = err
+		t.mu.Unlock()
+
+		t.options.ConnectionLost(interfaces.GOOGLE_TRANSCRIBER, err)
+		return
+	case streamClosed:
+		// google hung up on the stream we are still using
+		klog.V(2).Infof("Google stream closed. Reconnecting...\n")
+		err = status.Error(codes.Unavailable, "google stream closed")
+	default:
+		klog.V(2).Infof("Google stream failed. Err: %v. Reconnecting...\n", err)
+	}
+
+	t.mu.Lock()
+	t.state = interfaces.ConnectionReconnecting
+	t.mu.Unlock()
+
+	lostAt := time.Now()
+	t.options.ConnectionLost(interfaces.GOOGLE_TRANSCRIBER, err)
+
+	if t.reconnect(stream) {
+		t.options.ConnectionRestored(interfaces.GOOGLE_TRANSCRIBER, time.Since(lostAt))
+	}
+}
+
+// handleResponse delivers final results as transcripts and whatever is left over as a partial
+func (t *Transcribe) handleResponse(stream *recognizeStream, resp *speechpb.StreamingRecognizeResponse) {
+	var sb strings.Builder
+	for _, result := range resp.Results {
+		if len(result.Alternatives) == 0 {
+			continue
+		}
+
+		if stream.utteranceID == "" {
+			stream.utteranceID = config.NewUtteranceID()
+		}
+		sb.WriteString(result.Alternatives[0].Transcript)
+
+		if !result.IsFinal {
+			continue
+		}
+
+		sentence := sb.String()
+		sb.Reset()
+		klog.V(3).Infof("google transcription: text=%s final=%t\n", sentence, result.IsFinal)
+
+		transcript := newTranscript(sentence, t.options.Language, result)
+		transcript.UtteranceID = stream.utteranceID
+		stream.utteranceID = ""
+
+		// audio up to here no longer needs replaying
+		t.mu.Lock()
+		transcript.Latency = t.latencyLocked(stream, result)
+		stream.forget(result.ResultEndTime.AsDuration())
+		t.mu.Unlock()
+		klog.V(4).Infof("google latency: %v\n", transcript.Latency)
+
+		// end the recording here, before the reply is handled and spoken
+		if t.options.Recorder != nil {
+			err := t.options.Recorder.Segment(sentence)
+			if err != nil {
+				klog.V(1).Infof("Recorder.Segment failed. Err: %v\n", err)
+			}
+		}
+
+		if callback := t.options.GetTranscriptCallback(); callback != nil {
+			t.source.Mute()
+			err := callback.Transcript(transcript)
+			if err != nil {
+				klog.V(1).Infof("callback.Transcript failed. Err: %v\n", err)
+			}
+			t.source.Unmute()
+		} else {
+			klog.V(2).Infof("stream.Recv() text=%s final=%t\n", sentence, result.IsFinal)
+		}
+
+		t.options.UtteranceEnded(transcript.UtteranceID, transcript.Channel)
+	}
+
+	// whatever is left over has not been finalized yet
+	if sb.Len() > 0 {
+		t.partial(stream, sb.String(), resp.Results[len(resp.Results)-1])
+	}
+}
+
+// partial delivers an interim result for the current utterance, if anyone is listening
+func (t *Transcribe) partial(stream *recognizeStream, text string, result *speechpb.StreamingRecognitionResult) {
+	callback := t.options.GetPartialCallback()
+	if callback == nil {
+		return
+	}
+
+	transcript := newTranscript(text, t.options.Language, result)
+	transcript.UtteranceID = stream.utteranceID
+
+	t.mu.Lock()
+	transcript.Latency = t.latencyLocked(stream, result)
+	t.mu.Unlock()
+
+	err := callback.PartialTranscript(transcript)
+	if err != nil {
+		klog.V(1).Infof("callback.PartialTranscript failed. Err: %v\n", err)
+	}
+}
+
+// latencyLocked how long ago the audio at the end of result was captured. Must be
+// called with t.mu held.
+func (t *Transcribe) latencyLocked(stream *recognizeStream, result *speechpb.StreamingRecognitionResult) time.Duration {
+	if result.ResultEndTime == nil {
+		return 0
+	}
+
+	capturedAt, ok := stream.capturedAt(result.ResultEndTime.AsDuration())
+	if !ok {
+		return 0
+	}
+	return time.Since(capturedAt)
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package google
+
+import (
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	speechpb "cloud.google.com/go/speech/apiv1/speechpb"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// recognizeStream is a single StreamingRecognize call along with the audio it has
+// been sent but not finalized yet, so the audio can be replayed if the call fails
+type recognizeStream struct {
+	client  speechpb.Speech_StreamingRecognizeClient
+	started time.Time
+
+	pending      []audioChunk
+	pendingBytes int
+	remembered   int64
+	failed       bool
+
+	// when the audio sent so far was captured, to measure latency. Google reports
+	// offsets into the audio of this stream.
+	sent           int64
+	marks          []sendMark
+	bytesPerSecond int64
+
+	// shared by the partials and final transcript of the current utterance. Only
+	// touched by the stream's listener.
+	utteranceID string
+}
+
+// audioChunk audio waiting to be finalized. end is the offset in bytes into the
+// stream's audio where the chunk finishes.
+type audioChunk struct {
+	data       []byte
+	capturedAt time.Time
+	end        int64
+}
+
+// sendMark the audio captured at capturedAt took the stream up to end bytes
+type sendMark struct {
+	end        int64
+	capturedAt time.Time
+}
+
+// remember keeps a copy of the audio until a final result covers it. Guarded by Transcribe.mu.
+func (s *recognizeStream) remember(buf []byte, capturedAt time.Time, maxBytes int) {
+	cp := make([]byte, len(buf))
+	copy(cp, buf)
+	s.remembered += int64(len(cp))
+	s.pending = append(s.pending, audioChunk{data: cp, capturedAt: capturedAt, end: s.remembered})
+	s.pendingBytes += len(cp)
+
+	for s.pendingBytes > maxBytes && len(s.pending) > 1 {
+		s.pendingBytes -= len(s.pending[0].data)
+		s.pending = s.pending[1:]
+	}
+}
+
+// forget drops the audio once it has been finalized up to offset. Audio after the
+// offset has not been finalized yet and is kept for replay. Guarded by Transcribe.mu.
+func (s *recognizeStream) forget(offset time.Duration) {
+	finalized := s.offsetBytes(offset)
+
+	i := 0
+	for i < len(s.pending) && s.pending[i].end <= finalized {
+		s.pendingBytes -= len(s.pending[i].data)
+		i++
+	}
+	s.pending = s.pending[i:]
+
+	i = 0
+	for i < len(s.marks) && s.marks[i].end <= finalized {
+		i++
+	}
+	s.marks = s.marks[i:]
+}
+
+// send passes audio on to google. Guarded by Transcribe.mu.
+func (s *recognizeStream) send(buf []byte, capturedAt time.Time) error {
+	err := s.client.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
+			AudioContent: buf,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sent += int64(len(buf))
+	s.marks = append(s.marks, sendMark{end: s.sent, capturedAt: capturedAt})
+	return nil
+}
+
+// capturedAt returns when the audio at offset into the stream was captured. Guarded by Transcribe.mu.
+func (s *recognizeStream) capturedAt(offset time.Duration) (time.Time, bool) {
+	bytes := s.offsetBytes(offset)
+	for _, mark := range s.marks {
+		if mark.end >= bytes {
+			return mark.capturedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (s *recognizeStream) offsetBytes(offset time.Duration) int64 {
+	return int64(offset) * s.bytesPerSecond / int64(time.Second)
+}
+
+// openStream starts a new StreamingRecognize call and sends the recognition config
+func (t *Transcribe) openStream() (*recognizeStream, error) {
+	client, err := t.googleClient.StreamingRecognize(t.ctx)
+	if err != nil {
+		klog.V(1).Infof("googleClient.StreamingRecognize failed. Err: %v\n", err)
+		return nil, err
+	}
+
+	err = client.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				InterimResults: true,
+				Config:         t.recognitionConfig(),
+			},
+		},
+	})
+	if err != nil {
+		klog.V(1).Infof("client.Send failed. Err: %v\n", err)
+		client.CloseSend()
+		return nil, err
+	}
+
+	return &recognizeStream{
+		client:         client,
+		started:        time.Now(),
+		bytesPerSecond: int64(t.options.SamplingRate * t.options.InputChannels * 2),
+	}, nil
+}
+
+// rotateLocked replaces the stream before Google's streaming limit is reached. The
+// old stream is half-closed so it still finalizes what it has already heard, which
+// means nothing needs to be replayed. Must be called with t.mu held.
+func (t *Transcribe) rotateLocked() {
+	klog.V(4).Infof("Rotating google stream after %v\n", time.Since(t.stream.started))
+
+	next, err := t.openStream()
+	if err != nil {
+		// keep using the old one, reconnect picks up if it runs out
+		klog.V(1).Infof("openStream failed. Err: %v\n", err)
+		return
+	}
+
+	old := t.stream
+	t.stream = next
+	go t.listen(next)
+
+	err = old.client.CloseSend()
+	if err != nil {
+		klog.V(1).Infof("client.CloseSend failed. Err: %v\n", err)
+	}
+}
+
+// reconnect replaces a failed stream, retrying with backoff until it succeeds or the
+// transcriber is stopped. Audio which was never finalized on the failed stream, and
+// anything captured in the meantime, is replayed on the new one. Returns whether
+// this call reconnected.
+func (t *Transcribe) reconnect(failed *recognizeStream) bool {
+	backoff := DefaultReconnectBackoff
+
+	for {
+		if t.ctx.Err() != nil {
+			return false
+		}
+
+		next, err := t.openStream()
+		if err == nil {
+			t.mu.Lock()
+			if t.stream != failed {
+				// already replaced by a rotation
+				t.mu.Unlock()
+				next.client.CloseSend()
+				return false
+			}
+
+			for _, chunk := range failed.pending {
+				err = next.send(chunk.data, chunk.capturedAt)
+				if err != nil {
+					break
+				}
+				next.remember(chunk.data, chunk.capturedAt, t.maxPendingBytes)
+			}
+			if err == nil {
+				klog.V(3).Infof("Google stream reconnected. Replayed %d bytes\n", failed.pendingBytes)
+				t.stream = next
+				t.state = interfaces.ConnectionConnected
+				t.mu.Unlock()
+
+				go t.listen(next)
+				return true
+			}
+			t.mu.Unlock()
+
+			klog.V(1).Infof("replaying audio failed. Err: %v\n", err)
+			next.client.CloseSend()
+		}
+
+		klog.V(2).Infof("Google stream reconnect failed. Retrying in %v\n", backoff)
+		select {
+		case <-t.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > DefaultMaxReconnectBackoff {
+			backoff = DefaultMaxReconnectBackoff
+		}
+	}
+}
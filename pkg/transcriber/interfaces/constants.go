@@ -0,0 +1,28 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+// built-in transcriber names
+const (
+	DEEPGRAM_TRANSCRIBER string = "deepgram"
+	GOOGLE_TRANSCRIBER   string = "google"
+	FAKE_TRANSCRIBER     string = "fake"
+
+	DEFAULT_TRANSCRIBER = GOOGLE_TRANSCRIBER
+)
+
+// ConnectionState of a transcriber's connection to its backend
+type ConnectionState int
+
+const (
+	ConnectionDisconnected ConnectionState = iota
+	ConnectionConnected
+	ConnectionReconnecting
+)
+
+// recognition
+const (
+	DefaultLanguageCode string = "en-US"
+)
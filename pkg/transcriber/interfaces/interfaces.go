@@ -4,6 +4,64 @@
 
 package interfaces
 
+import (
+	"context"
+	"time"
+)
+
+// Transcriber turns audio into sentences delivered to a ResponseCallback
+type Transcriber interface {
+	Start() error
+	Stop() error
+}
+
+// FileTranscriber transcribes a whole recording in one request instead of streaming it
+type FileTranscriber interface {
+	TranscribeFile(ctx context.Context, filePath string) (*FileTranscript, error)
+	Close() error
+}
+
 type ResponseCallback interface {
 	Response(sentence string) error
 }
+
+// TranscriptCallback receives the full transcript instead of just the sentence
+type TranscriptCallback interface {
+	Transcript(t *Transcript) error
+}
+
+// PartialTranscriptCallback receives interim results while someone is still speaking.
+// Partials and the final transcript for the same utterance share an UtteranceID.
+type PartialTranscriptCallback interface {
+	PartialTranscript(t *Transcript) error
+}
+
+// UtteranceEndCallback is told when the user's turn is over, after its final transcript
+type UtteranceEndCallback interface {
+	UtteranceEnd(utteranceID string, channel int) error
+}
+
+// ChannelResponseCallback can be implemented alongside ResponseCallback to find out
+// which input channel a sentence came from when channels are transcribed separately
+type ChannelResponseCallback interface {
+	ChannelResponse(channel int, sentence string) error
+}
+
+// ConnectionStatusCallback is notified when a transcriber loses and regains the
+// connection to its backend. Audio heard in the meantime is buffered and replayed.
+type ConnectionStatusCallback interface {
+	ConnectionLost(backend string, err error) error
+	ConnectionRestored(backend string, downtime time.Duration) error
+}
+
+// BackendSwitchCallback can be implemented alongside ConnectionStatusCallback to find
+// out when failover moves from one backend to another. Without it the switch is
+// reported as ConnectionRestored for the new backend, after ConnectionLost for the old.
+type BackendSwitchCallback interface {
+	BackendSwitched(from, to string, downtime time.Duration) error
+}
+
+// ConnectionReporter is implemented by transcribers which hold a connection to a backend
+type ConnectionReporter interface {
+	ConnectionState() ConnectionState
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+import (
+	"time"
+)
+
+// Word a single recognized word. Start and End are offsets from the start of the stream.
+type Word struct {
+	Word       string
+	Start      time.Duration
+	End        time.Duration
+	Confidence float64
+}
+
+// Alternative one possible interpretation of what was said
+type Alternative struct {
+	Text       string
+	Confidence float64
+	Words      []Word
+}
+
+// Transcript everything a backend knows about a piece of recognized speech
+type Transcript struct {
+	// Text is the best alternative
+	Text       string
+	Confidence float64
+	Words      []Word
+
+	// Alternatives holds every alternative, best first
+	Alternatives []Alternative
+
+	Language string
+	Channel  int
+	IsFinal  bool
+
+	// UtteranceID is shared by the partials and the final transcript of an utterance
+	UtteranceID string
+
+	// Backend name of the transcriber which produced this transcript
+	Backend   string
+	Timestamp time.Time
+
+	// Latency from capturing the end of the speech to delivering the transcript.
+	// Zero when the backend does not measure it.
+	Latency time.Duration
+}
+
+// Segment a transcript positioned within a recording
+type Segment struct {
+	Transcript
+
+	// Start and End are offsets from the start of the recording
+	Start time.Duration
+	End   time.Duration
+}
+
+// FileTranscript everything recognized in a recording, in order
+type FileTranscript struct {
+	// Text is the whole recording, one segment per line
+	Text     string
+	Segments []Segment
+
+	Language string
+	Duration time.Duration
+	Backend  string
+}
+
+// ResponseAdapter delivers transcripts to a ResponseCallback as bare sentences
+type ResponseAdapter struct {
+	Callback ResponseCallback
+}
+
+// Transcript implements TranscriptCallback
+func (r *ResponseAdapter) Transcript(t *Transcript) error {
+	return r.Callback.Response(t.Text)
+}
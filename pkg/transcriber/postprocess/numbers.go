@@ -0,0 +1,134 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package postprocess
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	smallNumbers = map[string]int64{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+		"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+		"thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17,
+		"eighteen": 18, "nineteen": 19, "twenty": 20, "thirty": 30, "forty": 40,
+		"fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	scaleNumbers = map[string]int64{
+		"thousand": 1000, "million": 1000000, "billion": 1000000000,
+	}
+)
+
+// Numbers turns spoken numbers into digits, "twenty one" becomes "21" and
+// "two hundred and five" becomes "205". Expects lowercase text without punctuation
+// around the numbers, so run it after Lowercase and StripPunctuation.
+func Numbers() Stage {
+	return StageFunc(func(text string) string {
+		words := strings.Fields(text)
+		out := make([]string, 0, len(words))
+
+		for i := 0; i < len(words); {
+			value, n := parseNumber(words[i:])
+			if n == 0 {
+				out = append(out, words[i])
+				i++
+				continue
+			}
+			out = append(out, strconv.FormatInt(value, 10))
+			i += n
+		}
+		return strings.Join(out, " ")
+	})
+}
+
+// parseNumber reads the longest spoken number at the start of words, returning its
+// value and how many words it used
+func parseNumber(words []string) (int64, int) {
+	var total, current int64
+	used := 0
+	// what the last word was, so "one two" stays two numbers
+	lastSmall := int64(-1)
+	lastWasHundred := false
+
+	for i, word := range words {
+		word = strings.ReplaceAll(word, "-", " ")
+		parts := strings.Fields(word)
+
+		// "twenty-one" is a single word with two parts
+		if len(parts) == 2 {
+			tens, okTens := smallNumbers[parts[0]]
+			units, okUnits := smallNumbers[parts[1]]
+			if !okTens || !okUnits || tens < 20 || tens%10 != 0 || units == 0 || units > 9 || !canFollow(lastSmall, tens) {
+				break
+			}
+			current += tens + units
+			lastSmall = units
+			lastWasHundred = false
+			used = i + 1
+			continue
+		}
+		if len(parts) != 1 {
+			break
+		}
+
+		switch {
+		case word == "and":
+			// only part of a number in "hundred and five"
+			if !lastWasHundred || i+1 >= len(words) {
+				return total + current, used
+			}
+			if _, ok := smallNumbers[words[i+1]]; !ok {
+				return total + current, used
+			}
+			continue
+		case word == "hundred":
+			// "two hundred", but not "hundred" alone or "two hundred hundred"
+			if used == 0 || current <= 0 || current >= 100 {
+				return total + current, used
+			}
+			current *= 100
+			lastSmall = 100
+			lastWasHundred = true
+		default:
+			if scale, ok := scaleNumbers[word]; ok {
+				if used == 0 || current == 0 {
+					return total + current, used
+				}
+				total += current * scale
+				current = 0
+				lastSmall = -1
+				lastWasHundred = false
+				used = i + 1
+				continue
+			}
+
+			value, ok := smallNumbers[word]
+			if !ok || (used > 0 && !canFollow(lastSmall, value)) {
+				return total + current, used
+			}
+			current += value
+			lastSmall = value
+			lastWasHundred = false
+		}
+		used = i + 1
+	}
+
+	return total + current, used
+}
+
+// canFollow reports whether value continues a number ending in last, e.g. "twenty" "one"
+func canFollow(last, value int64) bool {
+	switch {
+	case last < 0:
+		// start of a number or straight after a scale word
+		return true
+	case last == 100:
+		return value > 0 && value < 100
+	case last >= 20 && last%10 == 0:
+		return value > 0 && value < 10
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package postprocess
+
+import (
+	"strings"
+
+	klog "k8s.io/klog/v2"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// New creates a pipeline running the stages in order
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{
+		Stages: stages,
+	}
+}
+
+// Default lowercases transcripts, which is what the transcribers used to do on their own
+func Default() *Pipeline {
+	return New(Lowercase())
+}
+
+// Process implements Stage
+func (f StageFunc) Process(text string) string {
+	return f(text)
+}
+
+// Process runs every stage over the text
+func (p *Pipeline) Process(text string) string {
+	for _, stage := range p.Stages {
+		text = stage.Process(text)
+	}
+	return text
+}
+
+// Apply returns a copy of the transcript with its text and alternatives processed.
+// Words are left as the recognizer heard them.
+func (p *Pipeline) Apply(t *interfaces.Transcript) *interfaces.Transcript {
+	processed := *t
+	processed.Text = p.Process(t.Text)
+
+	processed.Alternatives = make([]interfaces.Alternative, len(t.Alternatives))
+	for i, alt := range t.Alternatives {
+		processed.Alternatives[i] = alt
+		processed.Alternatives[i].Text = p.Process(alt.Text)
+	}
+
+	return &processed
+}
+
+// TranscriptCallback wraps callback so it receives processed transcripts. Transcripts
+// with nothing left after processing, like a lone "um", are dropped.
+func TranscriptCallback(pipeline *Pipeline, callback interfaces.TranscriptCallback) interfaces.TranscriptCallback {
+	return &transcriptCallback{
+		pipeline: pipeline,
+		callback: callback,
+	}
+}
+
+// PartialCallback wraps callback so it receives processed partial transcripts
+func PartialCallback(pipeline *Pipeline, callback interfaces.PartialTranscriptCallback) interfaces.PartialTranscriptCallback {
+	return &partialCallback{
+		pipeline: pipeline,
+		callback: callback,
+	}
+}
+
+// Transcript implements interfaces.TranscriptCallback
+func (c *transcriptCallback) Transcript(t *interfaces.Transcript) error {
+	processed := c.pipeline.Apply(t)
+	if strings.TrimSpace(processed.Text) == "" {
+		klog.V(4).Infof("Nothing left after post-processing = %s\n", t.Text)
+		return nil
+	}
+	return c.callback.Transcript(processed)
+}
+
+// PartialTranscript implements interfaces.PartialTranscriptCallback
+func (c *partialCallback) PartialTranscript(t *interfaces.Transcript) error {
+	return c.callback.PartialTranscript(c.pipeline.Apply(t))
+}
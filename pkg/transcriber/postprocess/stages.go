@@ -0,0 +1,115 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package postprocess
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Lowercase lowercases the text
+func Lowercase() Stage {
+	return StageFunc(strings.ToLower)
+}
+
+// StripPunctuation removes punctuation, keeping apostrophes and hyphens inside words
+// so "don't" and "wi-fi" survive
+func StripPunctuation() Stage {
+	return StageFunc(func(text string) string {
+		runes := []rune(text)
+		var sb strings.Builder
+		for i, r := range runes {
+			if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+				sb.WriteRune(r)
+				continue
+			}
+
+			inWord := i > 0 && i < len(runes)-1 && isWordRune(runes[i-1]) && isWordRune(runes[i+1])
+			if (r == '\'' || r == '-') && inWord {
+				sb.WriteRune(r)
+				continue
+			}
+
+			// "end.start" should not become "endstart"
+			sb.WriteRune(' ')
+		}
+		return strings.Join(strings.Fields(sb.String()), " ")
+	})
+}
+
+// RemoveFillers drops filler words, DefaultFillers when none are given
+func RemoveFillers(fillers ...string) *FillerRemover {
+	if len(fillers) == 0 {
+		fillers = DefaultFillers
+	}
+
+	f := &FillerRemover{
+		fillers: make(map[string]bool),
+	}
+	for _, filler := range fillers {
+		f.fillers[strings.ToLower(filler)] = true
+	}
+	return f
+}
+
+// Process implements Stage
+func (f *FillerRemover) Process(text string) string {
+	words := strings.Fields(text)
+	kept := words[:0]
+	for _, word := range words {
+		bare := strings.TrimFunc(strings.ToLower(word), func(r rune) bool {
+			return !isWordRune(r)
+		})
+		if f.fillers[bare] {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	return strings.Join(kept, " ")
+}
+
+// Replace swaps whole words and phrases using the map, ignoring case. Longer phrases
+// win, so {"hey kid": "hey kitt", "kid": "kitt"} does what you would expect.
+func Replace(replacements map[string]string) *Replacer {
+	phrases := make([]string, 0, len(replacements))
+	for phrase := range replacements {
+		if strings.TrimSpace(phrase) != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if len(phrases[i]) != len(phrases[j]) {
+			return len(phrases[i]) > len(phrases[j])
+		}
+		return phrases[i] < phrases[j]
+	})
+
+	r := &Replacer{}
+	for _, phrase := range phrases {
+		words := strings.Fields(phrase)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := `(?i)\b` + strings.Join(words, `\s+`) + `\b`
+
+		r.patterns = append(r.patterns, regexp.MustCompile(pattern))
+		r.replacements = append(r.replacements, replacements[phrase])
+	}
+	return r
+}
+
+// Process implements Stage
+func (r *Replacer) Process(text string) string {
+	for i, pattern := range r.patterns {
+		text = pattern.ReplaceAllLiteralString(text, r.replacements[i])
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package postprocess
+
+import (
+	"regexp"
+
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// Stage transforms transcript text. Stages are independent and can be used on their own.
+type Stage interface {
+	Process(text string) string
+}
+
+// StageFunc adapts a plain function into a Stage
+type StageFunc func(text string) string
+
+// Pipeline runs stages in order
+type Pipeline struct {
+	Stages []Stage
+}
+
+// Replacer swaps whole words and phrases, ignoring case
+type Replacer struct {
+	patterns     []*regexp.Regexp
+	replacements []string
+}
+
+// FillerRemover drops hesitation words like "um" and "uh"
+type FillerRemover struct {
+	fillers map[string]bool
+}
+
+// transcriptCallback runs a pipeline over transcripts before handing them on
+type transcriptCallback struct {
+	pipeline *Pipeline
+	callback interfaces.TranscriptCallback
+}
+
+// partialCallback runs a pipeline over partial transcripts before handing them on
+type partialCallback struct {
+	pipeline *Pipeline
+	callback interfaces.PartialTranscriptCallback
+}
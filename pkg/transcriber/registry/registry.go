@@ -0,0 +1,126 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	klog "k8s.io/klog/v2"
+
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+// Factory creates a transcriber backend
+type Factory func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.Transcriber, error)
+
+// FileFactory creates a backend which transcribes recordings in one request
+type FileFactory func(ctx context.Context, opts *config.TranscribeOptions) (interfaces.FileTranscriber, error)
+
+var (
+	// ErrUnknownTranscriber no backend was registered with the requested name
+	ErrUnknownTranscriber = errors.New("unknown transcriber")
+)
+
+var (
+	factories     = make(map[string]Factory)
+	fileFactories = make(map[string]FileFactory)
+	mu            sync.RWMutex
+)
+
+// Register adds a backend by name, replacing any backend already registered with that name
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		klog.V(3).Infof("Replacing transcriber %s\n", name)
+	}
+	factories[name] = factory
+}
+
+// New creates the transcriber registered under name
+func New(ctx context.Context, name string, opts *config.TranscribeOptions) (interfaces.Transcriber, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+
+	if !ok {
+		klog.V(1).Infof("Transcriber %s not registered. Available: %v\n", name, Names())
+		return nil, ErrUnknownTranscriber
+	}
+
+	transcriber, err := factory(ctx, opts)
+	if err != nil {
+		klog.V(1).Infof("Transcriber %s failed to create. Err: %v\n", name, err)
+		return nil, err
+	}
+
+	klog.V(4).Infof("Transcriber %s created\n", name)
+	return transcriber, nil
+}
+
+// Names returns the registered backends in sorted order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RegisterFile adds a file backend by name, replacing any registered with that name
+func RegisterFile(name string, factory FileFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := fileFactories[name]; ok {
+		klog.V(3).Infof("Replacing file transcriber %s\n", name)
+	}
+	fileFactories[name] = factory
+}
+
+// NewFile creates the file transcriber registered under name
+func NewFile(ctx context.Context, name string, opts *config.TranscribeOptions) (interfaces.FileTranscriber, error) {
+	mu.RLock()
+	factory, ok := fileFactories[name]
+	mu.RUnlock()
+
+	if !ok {
+		klog.V(1).Infof("File transcriber %s not registered. Available: %v\n", name, FileNames())
+		return nil, ErrUnknownTranscriber
+	}
+
+	transcriber, err := factory(ctx, opts)
+	if err != nil {
+		klog.V(1).Infof("File transcriber %s failed to create. Err: %v\n", name, err)
+		return nil, err
+	}
+
+	klog.V(4).Infof("File transcriber %s created\n", name)
+	return transcriber, nil
+}
+
+// FileNames returns the registered file backends in sorted order
+func FileNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(fileFactories))
+	for name := range fileFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package segmenter
+
+import (
+	"strings"
+	"time"
+
+	klog "k8s.io/klog/v2"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+	postprocess "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/postprocess"
+)
+
+// New creates a segmenter delivering merged turns to the callbacks in opts. The source
+// is muted while a turn is being handled, like the backends do for their own callbacks.
+func New(opts *config.TranscribeOptions, source audio.AudioSource) *Segmenter {
+	s := &Segmenter{
+		options: opts,
+		source:  source,
+	}
+	if opts.Segmentation != nil {
+		s.segmentation = *opts.Segmentation
+	}
+	if s.segmentation.SilenceTimeout == 0 {
+		s.segmentation.SilenceTimeout = DefaultSilenceTimeout
+	}
+
+	return s
+}
+
+// Needed returns whether a backend has to segment locally. native is whether the
+// backend can apply SilenceTimeout and MaxUtterance itself.
+func Needed(opts *config.TranscribeOptions, native bool) bool {
+	if opts.Segmentation == nil {
+		return false
+	}
+	seg := opts.Segmentation
+	return seg.LocalAggregation || (!native && (seg.SilenceTimeout > 0 || seg.MaxUtterance > 0))
+}
+
+// Options returns a copy of the options with transcripts routed through the segmenter,
+// for the backend to use in place of the originals
+func (s *Segmenter) Options() *config.TranscribeOptions {
+	opts := *s.options
+
+	var callback interfaces.TranscriptCallback
+	callback = s
+	opts.TranscriptCallback = &callback
+	opts.Callback = nil
+
+	if s.options.PartialCallback != nil {
+		var partial interfaces.PartialTranscriptCallback
+		partial = s
+		opts.PartialCallback = &partial
+	}
+
+	// the segmenter decides when utterances end
+	opts.UtteranceEndCallback = nil
+
+	// and post-processes the merged turn, not each fragment
+	opts.PostProcess = postprocess.New()
+
+	return &opts
+}
+
+// Transcript implements interfaces.TranscriptCallback by adding the fragment to the
+// current turn. The turn is delivered once the speaker pauses or it gets too long.
+func (s *Segmenter) Transcript(t *interfaces.Transcript) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	current := s.currentLocked()
+	current.fragments = append(current.fragments, t)
+
+	tooLong := s.segmentation.MaxUtterance > 0 && time.Since(current.started) >= s.segmentation.MaxUtterance
+	if !tooLong {
+		s.resetTimerLocked()
+	}
+	s.mu.Unlock()
+
+	if tooLong {
+		klog.V(4).Infof("Utterance reached %v, ending the turn\n", s.segmentation.MaxUtterance)
+		s.Flush()
+	}
+	return nil
+}
+
+// PartialTranscript implements interfaces.PartialTranscriptCallback. The partial is
+// extended with the fragments already heard this turn and shares the turn's ID.
+func (s *Segmenter) PartialTranscript(t *interfaces.Transcript) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	current := s.currentLocked()
+	partial := *t
+	partial.UtteranceID = current.id
+	if len(current.fragments) > 0 {
+		partial.Text = strings.TrimSpace(joinText(current.fragments) + " " + t.Text)
+
+		// still talking
+		s.resetTimerLocked()
+	}
+	s.mu.Unlock()
+
+	callback := s.options.GetPartialCallback()
+	if callback == nil {
+		return nil
+	}
+
+	s.deliver.Lock()
+	defer s.deliver.Unlock()
+	return callback.PartialTranscript(&partial)
+}
+
+// Flush delivers the current turn, if there is one, without waiting for silence.
+// Turns are delivered one at a time, so a turn ending while the previous one is
+// still being answered waits for it and the source stays muted throughout.
+func (s *Segmenter) Flush() {
+	s.deliver.Lock()
+	defer s.deliver.Unlock()
+
+	s.mu.Lock()
+	current := s.turn
+	s.turn = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+
+	if current == nil || len(current.fragments) == 0 {
+		return
+	}
+
+	transcript := merge(current)
+	klog.V(3).Infof("Segmented turn: text = %s, fragments = %d\n", transcript.Text, len(current.fragments))
+
+	if callback := s.options.GetTranscriptCallback(); callback != nil {
+		s.source.Mute()
+		err := callback.Transcript(transcript)
+		if err != nil {
+			klog.V(1).Infof("callback.Transcript failed. Err: %v\n", err)
+		}
+		s.source.Unmute()
+	}
+
+	s.options.UtteranceEnded(transcript.UtteranceID, transcript.Channel)
+}
+
+// Close delivers anything still pending and ignores fragments from then on
+func (s *Segmenter) Close() {
+	s.Flush()
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// currentLocked returns the current turn, starting one if needed. Must be called with s.mu held.
+func (s *Segmenter) currentLocked() *turn {
+	if s.turn == nil {
+		s.turn = &turn{
+			id:      config.NewUtteranceID(),
+			started: time.Now(),
+		}
+	}
+	return s.turn
+}
+
+// resetTimerLocked restarts the silence countdown. Must be called with s.mu held.
+func (s *Segmenter) resetTimerLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(s.segmentation.SilenceTimeout, s.Flush)
+}
+
+// merge combines the fragments of a turn into a single final transcript
+func merge(current *turn) *interfaces.Transcript {
+	last := current.fragments[len(current.fragments)-1]
+
+	transcript := &interfaces.Transcript{
+		Text:        joinText(current.fragments),
+		Language:    last.Language,
+		Channel:     last.Channel,
+		IsFinal:     true,
+		UtteranceID: current.id,
+		Backend:     last.Backend,
+		Timestamp:   time.Now(),
+	}
+
+	var confidence float64
+	for _, fragment := range current.fragments {
+		confidence += fragment.Confidence
+		transcript.Words = append(transcript.Words, fragment.Words...)
+	}
+	transcript.Confidence = confidence / float64(len(current.fragments))
+
+	// alternatives only line up with the text for a single fragment
+	if len(current.fragments) == 1 {
+		transcript.Alternatives = last.Alternatives
+	} else {
+		transcript.Alternatives = []interfaces.Alternative{
+			{Text: transcript.Text, Confidence: transcript.Confidence, Words: transcript.Words},
+		}
+	}
+
+	return transcript
+}
+
+func joinText(fragments []*interfaces.Transcript) string {
+	texts := make([]string, 0, len(fragments))
+	for _, fragment := range fragments {
+		if text := strings.TrimSpace(fragment.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
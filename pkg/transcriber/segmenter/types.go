@@ -0,0 +1,41 @@
+// Copyright 2023 The dvonthenen Open-Virtual-Assistant Authors. All Rights Reserved.
+// Use of this source code is governed by an Apache-2.0 license that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package segmenter
+
+import (
+	"sync"
+	"time"
+
+	audio "github.com/dvonthenen/open-virtual-assistant/pkg/audio/interfaces"
+	config "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/config"
+	interfaces "github.com/dvonthenen/open-virtual-assistant/pkg/transcriber/interfaces"
+)
+
+const (
+	// DefaultSilenceTimeout ends a turn when no SilenceTimeout is configured
+	DefaultSilenceTimeout = time.Second
+)
+
+// Segmenter merges final fragments from a backend into one transcript per turn
+type Segmenter struct {
+	options      *config.TranscribeOptions
+	segmentation config.Segmentation
+	source       audio.AudioSource
+
+	mu     sync.Mutex
+	turn   *turn
+	timer  *time.Timer
+	closed bool
+
+	// held while calling back so the timers and the backend take turns
+	deliver sync.Mutex
+}
+
+// turn the fragments heard so far from the current speaker
+type turn struct {
+	id        string
+	started   time.Time
+	fragments []*interfaces.Transcript
+}